@@ -0,0 +1,507 @@
+package systray
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultFrameDuration is the duration of animation frames that do not specify
+// it, such as frames of frame-sequence directories.
+const DefaultFrameDuration = 100 * time.Millisecond
+
+// animationExtensions lists extensions of animation files in order of
+// preference. Extension "/" matches frame-sequence directories.
+var animationExtensions = []string{".gif", "/", ".png"}
+
+// Frame is a single frame of [Animation].
+type Frame struct {
+	// Image of the frame, scaled to the size of the animation.
+	Image *image.NRGBA
+
+	// Duration of the frame.
+	Duration time.Duration
+}
+
+// Animation is a sequence of frames that is played in a loop.
+type Animation struct {
+	Frames []*Frame
+}
+
+// LoadAnimation resolves animation with the given name and decodes it into
+// frames scaled to fit into a square of size logical pixels at the given scale
+// factor.
+//
+// Name is either a full path or a name of an animation in the theme. Extra
+// directories are searched before base directories of the theme, as in
+// [IconTheme.Lookup]. The following formats are supported:
+//
+//   - GIF images, with durations of frames stored in the file
+//   - Directories of PNG images, one per frame, ordered by name
+//   - PNG sprite sheets with square frames in rows, as used by icon themes
+//     for animations such as process-working
+//
+// If theme is nil, [DefaultIconTheme] is used.
+func LoadAnimation(theme *IconTheme, name string, size int, scale float64, extraDirs ...string) (*Animation, error) {
+	if theme == nil {
+		theme = DefaultIconTheme()
+	}
+
+	path, ok := theme.lookup(theme.query(name, size, int(math.Ceil(scale)), animationExtensions, extraDirs))
+	if !ok {
+		return nil, fmt.Errorf("load animation: animation %s not found in theme %s", name, theme.name)
+	}
+
+	var animation *Animation
+
+	info, err := os.Stat(path)
+
+	switch {
+	case err != nil:
+	case info.IsDir():
+		animation, err = loadFrameDirectory(path)
+	case strings.EqualFold(filepath.Ext(path), ".gif"):
+		animation, err = loadGIF(path)
+	default:
+		animation, err = loadSpriteSheet(path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("load animation %s: %w", path, err)
+	}
+
+	if len(animation.Frames) == 0 {
+		return nil, fmt.Errorf("load animation %s: no frames", path)
+	}
+
+	target := targetSize(size, scale)
+
+	for _, frame := range animation.Frames {
+		frame.Image = ScaleImage(frame.Image, target, target)
+	}
+
+	return animation, nil
+}
+
+// AttentionAnimation loads AttentionMovieName of the item with
+// [LoadAnimation], searching IconThemePath of the item first.
+func (item *Item) AttentionAnimation(theme *IconTheme, size int, scale float64) (*Animation, error) {
+	if item.AttentionMovieName == "" {
+		return nil, fmt.Errorf("load animation: item has no attention movie")
+	}
+
+	return LoadAnimation(theme, item.AttentionMovieName, size, scale, item.iconThemeDirs()...)
+}
+
+// Duration returns total duration of a single loop of the animation.
+func (a *Animation) Duration() time.Duration {
+	var total time.Duration
+
+	for _, frame := range a.Frames {
+		total += frame.Duration
+	}
+
+	return total
+}
+
+// loadGIF decodes frames of GIF image at path. Frames are composed according
+// to their disposal methods, so that each frame is a complete image.
+func loadGIF(path string) (*Animation, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	g, err := gif.DecodeAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewNRGBA(bounds)
+	animation := &Animation{}
+
+	for idx, paletted := range g.Image {
+		var previous *image.NRGBA
+
+		disposal := byte(0)
+		if idx < len(g.Disposal) {
+			disposal = g.Disposal[idx]
+		}
+
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewNRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, paletted.Bounds(), paletted, paletted.Bounds().Min, draw.Over)
+
+		frame := image.NewNRGBA(bounds)
+		copy(frame.Pix, canvas.Pix)
+
+		duration := DefaultFrameDuration
+		if idx < len(g.Delay) && g.Delay[idx] > 1 {
+			// Delays are specified in hundredths of a second. Shorter delays are
+			// treated as default, as browsers do.
+			duration = time.Duration(g.Delay[idx]) * 10 * time.Millisecond
+		}
+
+		animation.Frames = append(animation.Frames, &Frame{
+			Image:    frame,
+			Duration: duration,
+		})
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, paletted.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return animation, nil
+}
+
+// loadFrameDirectory decodes PNG images in dir as frames, ordered by file name.
+// Numbers in file names are compared by value, so that "frame10.png" follows
+// "frame9.png".
+func loadFrameDirectory(dir string) (*Animation, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ".png") {
+			names = append(names, entry.Name())
+		}
+	}
+
+	slices.SortFunc(names, compareNatural)
+
+	animation := &Animation{}
+
+	for _, name := range names {
+		icon, err := loadIconFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		animation.Frames = append(animation.Frames, &Frame{
+			Image:    icon.Image(),
+			Duration: DefaultFrameDuration,
+		})
+	}
+
+	return animation, nil
+}
+
+// loadSpriteSheet decodes PNG image at path and splits it into square frames,
+// whose side is the smaller dimension of the image. Frames are read row by
+// row. Square images result in a single frame.
+func loadSpriteSheet(path string) (*Animation, error) {
+	icon, err := loadIconFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	img := icon.Image()
+	side := min(img.Rect.Dx(), img.Rect.Dy())
+
+	if side == 0 {
+		return nil, errors.New("empty image")
+	}
+
+	animation := &Animation{}
+
+	for y := 0; y+side <= img.Rect.Dy(); y += side {
+		for x := 0; x+side <= img.Rect.Dx(); x += side {
+			frame := image.NewNRGBA(image.Rect(0, 0, side, side))
+			draw.Draw(frame, frame.Rect, img, image.Pt(x, y), draw.Src)
+
+			animation.Frames = append(animation.Frames, &Frame{
+				Image:    frame,
+				Duration: DefaultFrameDuration,
+			})
+		}
+	}
+
+	return animation, nil
+}
+
+// compareNatural compares strings, treating sequences of digits as numbers.
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		aDigits := leadingDigits(a)
+		bDigits := leadingDigits(b)
+
+		if aDigits > 0 && bDigits > 0 {
+			aNum := strings.TrimLeft(a[:aDigits], "0")
+			bNum := strings.TrimLeft(b[:bDigits], "0")
+
+			if c := len(aNum) - len(bNum); c != 0 {
+				return c
+			}
+
+			if c := strings.Compare(aNum, bNum); c != 0 {
+				return c
+			}
+
+			a, b = a[aDigits:], b[bDigits:]
+			continue
+		}
+
+		if a[0] != b[0] {
+			return int(a[0]) - int(b[0])
+		}
+
+		a, b = a[1:], b[1:]
+	}
+
+	return len(a) - len(b)
+}
+
+// leadingDigits returns number of ASCII digits at the start of s.
+func leadingDigits(s string) int {
+	idx := 0
+
+	for idx < len(s) && s[idx] >= '0' && s[idx] <= '9' {
+		idx++
+	}
+
+	return idx
+}
+
+// Animator plays attention animations of items registered by [Host].
+//
+// Animation of an item starts when it enters [ItemStatusNeedsAttention] status
+// and has AttentionMovieName, and stops when its status changes. Frames are
+// delivered to the callback set by [Animator.OnFrame].
+type Animator struct {
+	theme *IconTheme
+	size  int
+	scale float64
+
+	// Removes callbacks registered in the host.
+	unlisten func()
+
+	mu        sync.Mutex
+	closed    bool
+	running   map[*Item]chan struct{}
+	unlistens map[*Item]func()
+	onFrame   func(item *Item, frame *Frame)
+	onStop    func(item *Item)
+}
+
+// animationFields are fields of [Item] that affect its attention animation.
+const animationFields = ItemFieldStatus |
+	ItemFieldAttentionMovieName |
+	ItemFieldIconThemePath
+
+// NewAnimator returns a new [Animator] that plays animations of items
+// registered by the host. Animations are loaded with [LoadAnimation] using
+// the theme, size, and scale.
+func NewAnimator(host *Host, theme *IconTheme, size int, scale float64) *Animator {
+	a := &Animator{
+		theme:     theme,
+		size:      size,
+		scale:     scale,
+		running:   make(map[*Item]chan struct{}),
+		unlistens: make(map[*Item]func()),
+		onFrame:   func(*Item, *Frame) {},
+		onStop:    func(*Item) {},
+	}
+
+	items, unlisten := host.listen(a.watch, a.unwatch)
+	a.unlisten = unlisten
+
+	for _, item := range items {
+		a.watch(item)
+	}
+
+	return a
+}
+
+// OnFrame sets callback that runs whenever an animated item should display
+// the next frame.
+func (a *Animator) OnFrame(callback func(item *Item, frame *Frame)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.onFrame = callback
+}
+
+// OnStop sets callback that runs when animation of an item stops, e.g. to
+// restore its regular icon.
+func (a *Animator) OnStop(callback func(item *Item)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.onStop = callback
+}
+
+// IsAnimating reports whether animation of the item is playing.
+func (a *Animator) IsAnimating(item *Item) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	_, exists := a.running[item]
+	return exists
+}
+
+// Close stops all animations and removes callbacks registered in the host and
+// its items. Animator cannot be reused after Close was called.
+func (a *Animator) Close() {
+	// Host runs its callbacks with its lock held, which then take a.mu.
+	a.unlisten()
+
+	a.mu.Lock()
+	for item, stop := range a.running {
+		close(stop)
+		delete(a.running, item)
+	}
+
+	unlistens := a.unlistens
+	a.unlistens = nil
+	a.closed = true
+	a.onFrame = nil
+	a.onStop = nil
+	a.mu.Unlock()
+
+	for _, unlisten := range unlistens {
+		unlisten()
+	}
+}
+
+// watch starts animation of the item if necessary, and restarts it whenever
+// relevant fields of the item change.
+func (a *Animator) watch(item *Item) {
+	unlisten := item.listen(func(update *ItemUpdate) {
+		if update.Fields&animationFields != 0 {
+			a.stop(item)
+			a.start(item)
+		}
+	})
+
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		unlisten()
+		return
+	}
+	if previous, exists := a.unlistens[item]; exists {
+		previous()
+	}
+	a.unlistens[item] = unlisten
+	a.mu.Unlock()
+
+	a.start(item)
+}
+
+// unwatch stops animation of the unregistered item and removes the callback
+// registered by watch.
+func (a *Animator) unwatch(item *Item) {
+	a.mu.Lock()
+	unlisten := a.unlistens[item]
+	delete(a.unlistens, item)
+	a.mu.Unlock()
+
+	if unlisten != nil {
+		unlisten()
+	}
+
+	a.stop(item)
+}
+
+// start starts animation of the item if it needs attention.
+func (a *Animator) start(item *Item) {
+	if item.Status != ItemStatusNeedsAttention || item.AttentionMovieName == "" {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, exists := a.running[item]; exists || a.closed {
+		return
+	}
+
+	stop := make(chan struct{})
+	a.running[item] = stop
+
+	go a.play(item, stop)
+}
+
+// stop stops animation of the item, if it is playing.
+func (a *Animator) stop(item *Item) {
+	a.mu.Lock()
+	stop, exists := a.running[item]
+	if exists {
+		close(stop)
+		delete(a.running, item)
+	}
+	onStop := a.onStop
+	a.mu.Unlock()
+
+	if exists && onStop != nil {
+		onStop(item)
+	}
+}
+
+// play loads animation of the item and delivers its frames until stop is
+// closed.
+func (a *Animator) play(item *Item, stop chan struct{}) {
+	animation, err := item.AttentionAnimation(a.theme, a.size, a.scale)
+	if err != nil {
+		item.reportError("Animate", "AttentionMovieName", err)
+
+		a.mu.Lock()
+		if a.running[item] == stop {
+			delete(a.running, item)
+		}
+		a.mu.Unlock()
+
+		return
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for idx := 0; ; idx = (idx + 1) % len(animation.Frames) {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+
+		frame := animation.Frames[idx]
+
+		a.mu.Lock()
+		onFrame := a.onFrame
+		a.mu.Unlock()
+
+		if onFrame != nil {
+			onFrame(item, frame)
+		}
+
+		// Static images are displayed once.
+		if len(animation.Frames) == 1 {
+			<-stop
+			return
+		}
+
+		timer.Reset(frame.Duration)
+	}
+}
@@ -0,0 +1,33 @@
+package systray
+
+import "testing"
+
+func TestAnimatorCloseRemovesListeners(t *testing.T) {
+	address := startTestBus(t)
+	h := NewHost(connectTestBus(t, address), 1)
+
+	item := addTestItem(t, h, newFakeItem(t, address))
+	baseline := len(item.listeners)
+
+	a := NewAnimator(h, nil, 24, 1)
+
+	if len(h.listeners) != 1 || len(item.listeners) != baseline+1 {
+		t.Fatalf("animator registered %d host and %d item listeners, want 1 and 1",
+			len(h.listeners), len(item.listeners)-baseline)
+	}
+
+	a.Close()
+
+	if len(h.listeners) != 0 {
+		t.Errorf("%d host listeners left after Close", len(h.listeners))
+	}
+
+	if len(item.listeners) != baseline {
+		t.Errorf("%d item listeners left after Close", len(item.listeners)-baseline)
+	}
+
+	added := addTestItem(t, h, newFakeItem(t, address))
+	if len(added.listeners) != baseline {
+		t.Errorf("closed animator registered %d listeners of a new item", len(added.listeners)-baseline)
+	}
+}
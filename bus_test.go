@@ -0,0 +1,340 @@
+package systray
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// testBusConfig is configuration of the private message bus started by tests.
+const testBusConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+  <limit name="max_match_rules_per_connection">50000</limit>
+  <limit name="max_connections_per_user">100000</limit>
+</busconfig>
+`
+
+// startTestBus starts a private message bus and returns its address. The bus
+// is stopped when the test ends. The test is skipped if dbus-daemon is not
+// installed.
+func startTestBus(tb testing.TB) string {
+	tb.Helper()
+
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		tb.Skip("dbus-daemon is not installed")
+	}
+
+	// Paths of unix sockets are limited in length, so that the directory
+	// returned by tb.TempDir may be too long.
+	dir, err := os.MkdirTemp("", "systray-bus-")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { os.RemoveAll(dir) })
+
+	config := filepath.Join(dir, "bus.conf")
+
+	if err := os.WriteFile(config, fmt.Appendf(nil, testBusConfig, dir), 0o600); err != nil {
+		tb.Fatal(err)
+	}
+
+	cmd := exec.Command(daemon, "--config-file="+config, "--print-address", "--nofork")
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		tb.Fatal(err)
+	}
+
+	tb.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		tb.Fatalf("read bus address: %v", err)
+	}
+
+	return strings.TrimSpace(address)
+}
+
+// connectTestBus returns a new connection to the bus, which is closed when the
+// test ends.
+func connectTestBus(tb testing.TB, address string) *dbus.Conn {
+	tb.Helper()
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	tb.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// fakeItem is a minimal StatusNotifierItem that serves properties from a map.
+type fakeItem struct {
+	conn *dbus.Conn
+
+	mu    sync.Mutex
+	props map[string]dbus.Variant
+
+	// getAllErr is returned by GetAll instead of properties, if set.
+	getAllErr *dbus.Error
+
+	// delay is added to every method call, to imitate a slow application.
+	delay time.Duration
+
+	getAllCalls int
+	getCalls    int
+}
+
+// newFakeItem exports a new [fakeItem] on its own connection to the bus.
+func newFakeItem(tb testing.TB, address string) *fakeItem {
+	tb.Helper()
+
+	f := &fakeItem{
+		conn: connectTestBus(tb, address),
+		props: map[string]dbus.Variant{
+			"Category":   dbus.MakeVariant("ApplicationStatus"),
+			"Id":         dbus.MakeVariant("fake"),
+			"Title":      dbus.MakeVariant("Fake"),
+			"Status":     dbus.MakeVariant("Active"),
+			"IconName":   dbus.MakeVariant("fake-icon"),
+			"ItemIsMenu": dbus.MakeVariant(false),
+		},
+	}
+
+	err := f.conn.Export(f, StatusNotifierItemPath, propertiesInterface)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return f
+}
+
+// name returns unique name of the item connection.
+func (f *fakeItem) name() string {
+	return f.conn.Names()[0]
+}
+
+// set changes value of the property.
+func (f *fakeItem) set(name string, value any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.props[name] = dbus.MakeVariant(value)
+}
+
+// emit emits StatusNotifierItem update signal.
+func (f *fakeItem) emit(member string) error {
+	return f.conn.Emit(StatusNotifierItemPath, StatusNotifierItemInterface+"."+member)
+}
+
+// emitPropertiesChanged emits PropertiesChanged signal with the changed value.
+func (f *fakeItem) emitPropertiesChanged(name string, value any) error {
+	return f.conn.Emit(
+		StatusNotifierItemPath,
+		propertiesInterface+".PropertiesChanged",
+		StatusNotifierItemInterface,
+		map[string]dbus.Variant{name: dbus.MakeVariant(value)},
+		[]string{},
+	)
+}
+
+// Get implements org.freedesktop.DBus.Properties.Get.
+func (f *fakeItem) Get(iface, name string) (dbus.Variant, *dbus.Error) {
+	f.mu.Lock()
+	f.getCalls++
+	value, ok := f.props[name]
+	delay := f.delay
+	f.mu.Unlock()
+
+	time.Sleep(delay)
+
+	if !ok {
+		return dbus.Variant{}, dbus.NewError("org.freedesktop.DBus.Error.InvalidArgs", []any{"no such property " + name})
+	}
+
+	return value, nil
+}
+
+// GetAll implements org.freedesktop.DBus.Properties.GetAll.
+func (f *fakeItem) GetAll(iface string) (map[string]dbus.Variant, *dbus.Error) {
+	f.mu.Lock()
+	f.getAllCalls++
+	props := make(map[string]dbus.Variant, len(f.props))
+	for name, value := range f.props {
+		props[name] = value
+	}
+	getAllErr := f.getAllErr
+	delay := f.delay
+	f.mu.Unlock()
+
+	time.Sleep(delay)
+
+	if getAllErr != nil {
+		return nil, getAllErr
+	}
+
+	return props, nil
+}
+
+// fakeMenuPath is object path of menus exported by [exportFakeMenu].
+const fakeMenuPath = "/MenuBar"
+
+// fakeMenu is a minimal com.canonical.dbusmenu object that serves its
+// properties.
+type fakeMenu struct {
+	conn *dbus.Conn
+}
+
+// exportFakeMenu exports a new [fakeMenu] at [fakeMenuPath] on connection of
+// the fake item and sets Menu property of the item.
+func exportFakeMenu(tb testing.TB, item *fakeItem) *fakeMenu {
+	tb.Helper()
+
+	m := &fakeMenu{conn: item.conn}
+
+	if err := m.conn.Export(m, fakeMenuPath, propertiesInterface); err != nil {
+		tb.Fatal(err)
+	}
+
+	item.set("Menu", dbus.ObjectPath(fakeMenuPath))
+
+	return m
+}
+
+// emit emits signal of the menu.
+func (m *fakeMenu) emit(member string, values ...any) error {
+	return m.conn.Emit(fakeMenuPath, MenuInterface+"."+member, values...)
+}
+
+// Get implements org.freedesktop.DBus.Properties.Get.
+func (m *fakeMenu) Get(iface, name string) (dbus.Variant, *dbus.Error) {
+	switch name {
+	case "Version":
+		return dbus.MakeVariant(uint32(3)), nil
+	case "Status":
+		return dbus.MakeVariant("normal"), nil
+	case "IconThemePath":
+		return dbus.MakeVariant([]string{}), nil
+	default:
+		return dbus.Variant{}, dbus.NewError("org.freedesktop.DBus.Error.InvalidArgs", []any{"no such property " + name})
+	}
+}
+
+// fakeItemCall is a method call received by [fakeItemMethods].
+type fakeItemCall struct {
+	method string
+	args   []any
+}
+
+// fakeItemMethods serves methods of StatusNotifierItem interface and records
+// their calls.
+type fakeItemMethods struct {
+	calls chan fakeItemCall
+
+	// tokenErr is returned by ProvideXdgActivationToken, if set.
+	mu       sync.Mutex
+	tokenErr *dbus.Error
+}
+
+// exportFakeItemMethods exports a new [fakeItemMethods] on connection of the
+// fake item.
+func exportFakeItemMethods(tb testing.TB, item *fakeItem) *fakeItemMethods {
+	tb.Helper()
+
+	m := &fakeItemMethods{calls: make(chan fakeItemCall, 64)}
+
+	if err := item.conn.Export(m, StatusNotifierItemPath, StatusNotifierItemInterface); err != nil {
+		tb.Fatal(err)
+	}
+
+	return m
+}
+
+// Scroll implements org.kde.StatusNotifierItem.Scroll.
+func (m *fakeItemMethods) Scroll(delta int32, orientation string) *dbus.Error {
+	m.calls <- fakeItemCall{"Scroll", []any{delta, orientation}}
+	return nil
+}
+
+// Activate implements org.kde.StatusNotifierItem.Activate.
+func (m *fakeItemMethods) Activate(x, y int32) *dbus.Error {
+	m.calls <- fakeItemCall{"Activate", []any{x, y}}
+	return nil
+}
+
+// SecondaryActivate implements org.kde.StatusNotifierItem.SecondaryActivate.
+func (m *fakeItemMethods) SecondaryActivate(x, y int32) *dbus.Error {
+	m.calls <- fakeItemCall{"SecondaryActivate", []any{x, y}}
+	return nil
+}
+
+// ProvideXdgActivationToken implements
+// org.kde.StatusNotifierItem.ProvideXdgActivationToken.
+func (m *fakeItemMethods) ProvideXdgActivationToken(token string) *dbus.Error {
+	m.calls <- fakeItemCall{"ProvideXdgActivationToken", []any{token}}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.tokenErr
+}
+
+// rejectToken makes ProvideXdgActivationToken return the error.
+func (m *fakeItemMethods) rejectToken(err *dbus.Error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tokenErr = err
+}
+
+// next returns the next recorded call, or fails the test if there is none
+// within timeout.
+func (m *fakeItemMethods) next(tb testing.TB, timeout time.Duration) fakeItemCall {
+	tb.Helper()
+
+	select {
+	case call := <-m.calls:
+		return call
+	case <-time.After(timeout):
+		tb.Fatal("method was not called")
+		return fakeItemCall{}
+	}
+}
+
+// expectNone fails the test if a call is recorded within timeout.
+func (m *fakeItemMethods) expectNone(tb testing.TB, timeout time.Duration) {
+	tb.Helper()
+
+	select {
+	case call := <-m.calls:
+		tb.Errorf("unexpected call %s%v", call.method, call.args)
+	case <-time.After(timeout):
+	}
+}
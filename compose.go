@@ -0,0 +1,261 @@
+package systray
+
+import (
+	"image"
+	"image/draw"
+	"maps"
+	"math"
+)
+
+// OverlayPlacement specifies position of the overlay icon relative to the main
+// icon.
+type OverlayPlacement int
+
+// Overlay placements.
+const (
+	OverlayBottomRight OverlayPlacement = iota
+	OverlayBottomLeft
+	OverlayTopRight
+	OverlayTopLeft
+	OverlayCenter
+)
+
+// DefaultOverlayScale is the default size of the overlay icon relative to the
+// main icon.
+const DefaultOverlayScale = 0.5
+
+// iconFields are fields of [Item] that affect its main and overlay icons.
+const iconFields = ItemFieldIconName |
+	ItemFieldIconPixmap |
+	ItemFieldOverlayIconName |
+	ItemFieldOverlayIconPixmap |
+	ItemFieldIconThemePath
+
+// Compositor draws overlay icon of [Item] over its main icon, as suggested by
+// the StatusNotifierItem specification.
+//
+// Composited images are cached per item, size, and scale until the item
+// receives NewIcon, NewOverlayIcon, or NewIconThemePath signal that changes
+// its icons, or the theme is reloaded. Compositors with the same theme,
+// placement, and overlay scale share cached images.
+type Compositor struct {
+	theme     *IconTheme
+	placement OverlayPlacement
+	scale     float64
+}
+
+// compositeKey identifies composited image in the cache of [Item].
+type compositeKey struct {
+	theme         *IconTheme
+	themeRevision uint64
+	placement     OverlayPlacement
+	overlayScale  float64
+	size          int
+	scale         float64
+}
+
+// maxComposites is the maximum number of composited images cached per item.
+// Cache is cleared once the limit is reached, since items are typically drawn
+// with a few sizes only.
+const maxComposites = 16
+
+// NewCompositor returns a new [Compositor] that resolves icon names with the
+// theme and draws overlay of overlayScale times the size of the main icon at
+// the placement.
+//
+// If theme is nil, [DefaultIconTheme] is used. If overlayScale is not in range
+// (0, 1], [DefaultOverlayScale] is used.
+func NewCompositor(theme *IconTheme, placement OverlayPlacement, overlayScale float64) *Compositor {
+	if theme == nil {
+		theme = DefaultIconTheme()
+	}
+
+	if overlayScale <= 0 || overlayScale > 1 {
+		overlayScale = DefaultOverlayScale
+	}
+
+	return &Compositor{
+		theme:     theme,
+		placement: placement,
+		scale:     overlayScale,
+	}
+}
+
+// Compose returns main icon of the item with its overlay icon drawn over it,
+// scaled to fit into a square of size logical pixels at the given scale
+// factor. If the item has no overlay icon, the main icon is returned. Nil is
+// returned if the item has no icon.
+//
+// The returned image is shared between callers and must not be modified.
+func (c *Compositor) Compose(item *Item, size int, scale float64) *image.NRGBA {
+	key := compositeKey{
+		theme:         c.theme,
+		themeRevision: c.theme.currentRevision(),
+		placement:     c.placement,
+		overlayScale:  c.scale,
+		size:          size,
+		scale:         scale,
+	}
+
+	item.mu.Lock()
+	cached, ok := item.composites[key]
+	revision := item.iconRevision
+	item.mu.Unlock()
+
+	if ok {
+		return cached
+	}
+
+	img := c.compose(item, size, scale)
+
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	// Icons changed while the image was composited.
+	if revision != item.iconRevision {
+		return img
+	}
+
+	if item.composites == nil {
+		item.composites = make(map[compositeKey]*image.NRGBA)
+	}
+
+	// Images composited before the theme was reloaded are never used again.
+	maps.DeleteFunc(item.composites, func(other compositeKey, _ *image.NRGBA) bool {
+		return other.theme == key.theme && other.themeRevision != key.themeRevision
+	})
+
+	if len(item.composites) >= maxComposites {
+		clear(item.composites)
+	}
+
+	item.composites[key] = img
+
+	return img
+}
+
+// compose composites icons of the item without caching.
+func (c *Compositor) compose(item *Item, size int, scale float64) *image.NRGBA {
+	main := item.ResolveIcon(c.theme, size, scale)
+	if main == nil {
+		return nil
+	}
+
+	overlaySize := max(int(math.Round(float64(size)*c.scale)), 1)
+
+	overlay := item.ResolveOverlayIcon(c.theme, overlaySize, scale)
+	if overlay == nil {
+		return main
+	}
+
+	dst := image.NewNRGBA(main.Bounds())
+	copy(dst.Pix, main.Pix)
+
+	// draw.Over blends in premultiplied space, which is alpha-correct for
+	// non-premultiplied images as well.
+	rect := c.overlayRect(dst.Bounds(), overlay.Bounds().Size())
+	draw.Draw(dst, rect, overlay, image.Point{}, draw.Over)
+
+	return dst
+}
+
+// overlayRect returns rectangle of the given size at the compositor placement
+// within bounds.
+func (c *Compositor) overlayRect(bounds image.Rectangle, size image.Point) image.Rectangle {
+	var origin image.Point
+
+	switch c.placement {
+	case OverlayBottomLeft:
+		origin = image.Pt(bounds.Min.X, bounds.Max.Y-size.Y)
+	case OverlayTopRight:
+		origin = image.Pt(bounds.Max.X-size.X, bounds.Min.Y)
+	case OverlayTopLeft:
+		origin = bounds.Min
+	case OverlayCenter:
+		origin = image.Pt(
+			bounds.Min.X+(bounds.Dx()-size.X)/2,
+			bounds.Min.Y+(bounds.Dy()-size.Y)/2,
+		)
+	default:
+		origin = bounds.Max.Sub(size)
+	}
+
+	return image.Rectangle{origin, origin.Add(size)}
+}
+
+// ResolveIcon returns main icon of the item, scaled to fit into a square of
+// size logical pixels at the given scale factor.
+//
+// IconName is resolved with the theme, searching IconThemePath of the item
+// first. If the name cannot be resolved or decoded, the best icon from
+// IconPixmap is used. Nil is returned if the item has no icon.
+//
+// Resolved icons are cached in [DefaultIconCache]. The returned image is
+// shared and must not be modified.
+func (item *Item) ResolveIcon(theme *IconTheme, size int, scale float64) *image.NRGBA {
+	return item.resolveIcon(theme, item.IconName, item.IconPixmap, size, scale)
+}
+
+// ResolveOverlayIcon returns overlay icon of the item, resolved as described in
+// [Item.ResolveIcon].
+func (item *Item) ResolveOverlayIcon(theme *IconTheme, size int, scale float64) *image.NRGBA {
+	return item.resolveIcon(theme, item.OverlayIconName, item.OverlayIconPixmap, size, scale)
+}
+
+// ResolveAttentionIcon returns attention icon of the item, resolved as
+// described in [Item.ResolveIcon].
+func (item *Item) ResolveAttentionIcon(theme *IconTheme, size int, scale float64) *image.NRGBA {
+	return item.resolveIcon(theme, item.AttentionIconName, item.AttentionIconPixmap, size, scale)
+}
+
+// resolveIcon returns icon with the given name resolved with the theme, or the
+// best icon from pixmaps.
+func (item *Item) resolveIcon(theme *IconTheme, name string, pixmaps *IconSet, size int, scale float64) *image.NRGBA {
+	if theme == nil {
+		theme = DefaultIconTheme()
+	}
+
+	if name != "" {
+		extraDirs := item.iconThemeDirs()
+
+		img := DefaultIconCache.image(themeKey(theme, name, extraDirs, size, scale), func() *image.NRGBA {
+			icon, err := theme.LoadIcon(name, size, int(math.Ceil(scale)), extraDirs...)
+			if err != nil {
+				return nil
+			}
+
+			physical := targetSize(size, scale)
+
+			return ScaleImage(icon, physical, physical)
+		})
+
+		if img != nil {
+			return img
+		}
+	}
+
+	return pixmaps.BestImage(size, scale)
+}
+
+// iconThemeDirs returns additional directories to search for icon themes.
+func (item *Item) iconThemeDirs() []string {
+	if item.IconThemePath == "" {
+		return nil
+	}
+
+	return []string{item.IconThemePath}
+}
+
+// invalidateComposites discards composited icons cached by [Compositor] if
+// update changes icons of the item.
+func (item *Item) invalidateComposites(update *ItemUpdate) {
+	if update.Fields&iconFields == 0 {
+		return
+	}
+
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	item.iconRevision++
+	clear(item.composites)
+}
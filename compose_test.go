@@ -0,0 +1,132 @@
+package systray
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// solidTestIconSet returns icon set with a single ARGB32 icon of the given
+// size and color.
+func solidTestIconSet(t *testing.T, size int32, c color.NRGBA) *IconSet {
+	t.Helper()
+
+	pixels := make([]byte, 0, size*size*4)
+	for range size * size {
+		pixels = append(pixels, c.A, c.R, c.G, c.B)
+	}
+
+	set, err := NewIconSetFromDBusProperty([][]any{{size, size, pixels}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return set
+}
+
+// newTestOverlayItem returns item with opaque red main icon and translucent
+// blue overlay icon.
+func newTestOverlayItem(t *testing.T) *Item {
+	t.Helper()
+
+	return &Item{
+		IconPixmap:        solidTestIconSet(t, 24, color.NRGBA{0xff, 0, 0, 0xff}),
+		OverlayIconPixmap: solidTestIconSet(t, 12, color.NRGBA{0, 0, 0xff, 0x80}),
+	}
+}
+
+func TestCompositorOverlayRect(t *testing.T) {
+	bounds := image.Rect(0, 0, 24, 24)
+	size := image.Pt(12, 12)
+
+	tests := []struct {
+		placement OverlayPlacement
+		want      image.Rectangle
+	}{
+		{OverlayBottomRight, image.Rect(12, 12, 24, 24)},
+		{OverlayBottomLeft, image.Rect(0, 12, 12, 24)},
+		{OverlayTopRight, image.Rect(12, 0, 24, 12)},
+		{OverlayTopLeft, image.Rect(0, 0, 12, 12)},
+		{OverlayCenter, image.Rect(6, 6, 18, 18)},
+	}
+
+	for _, tt := range tests {
+		c := NewCompositor(NewIconTheme("test", t.TempDir()), tt.placement, 0.5)
+
+		if got := c.overlayRect(bounds, size); got != tt.want {
+			t.Errorf("placement %d: overlayRect = %v, want %v", tt.placement, got, tt.want)
+		}
+	}
+}
+
+func TestCompositorCompose(t *testing.T) {
+	item := newTestOverlayItem(t)
+	c := NewCompositor(NewIconTheme("test", t.TempDir()), OverlayTopLeft, 0.5)
+
+	img := c.Compose(item, 24, 1)
+	if img == nil || img.Bounds() != image.Rect(0, 0, 24, 24) {
+		t.Fatalf("Compose = %v, want 24x24 image", img)
+	}
+
+	// Translucent overlay is blended over the main icon.
+	blended := img.NRGBAAt(0, 0)
+	if blended.A != 0xff || componentDiff(blended.R, 0x7f) > 2 || blended.G != 0 || componentDiff(blended.B, 0x80) > 2 {
+		t.Errorf("overlay pixel = %v, want blend of red and blue", blended)
+	}
+
+	if main := img.NRGBAAt(23, 23); main != (color.NRGBA{0xff, 0, 0, 0xff}) {
+		t.Errorf("main pixel = %v, want opaque red", main)
+	}
+
+	// Main icon shared through the cache must not be modified.
+	if main := item.ResolveIcon(c.theme, 24, 1).NRGBAAt(0, 0); main != (color.NRGBA{0xff, 0, 0, 0xff}) {
+		t.Errorf("main icon was modified: %v", main)
+	}
+}
+
+// componentDiff returns absolute difference between color components.
+func componentDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+
+	return b - a
+}
+
+func TestCompositorCache(t *testing.T) {
+	item := newTestOverlayItem(t)
+	theme := NewIconTheme("test", t.TempDir())
+
+	first := NewCompositor(theme, OverlayBottomRight, 0.5).Compose(item, 24, 1)
+
+	// Compositors with the same settings share cached images, so that a
+	// compositor created per draw does not grow the cache.
+	for range 100 {
+		if img := NewCompositor(theme, OverlayBottomRight, 0.5).Compose(item, 24, 1); img != first {
+			t.Fatal("image was not cached")
+		}
+	}
+
+	if len(item.composites) != 1 {
+		t.Fatalf("%d images cached, want 1", len(item.composites))
+	}
+
+	theme.Reload()
+
+	if img := NewCompositor(theme, OverlayBottomRight, 0.5).Compose(item, 24, 1); img == first {
+		t.Error("cached image was used after theme reload")
+	}
+
+	if len(item.composites) != 1 {
+		t.Errorf("%d images cached after reload, want 1", len(item.composites))
+	}
+
+	c := NewCompositor(theme, OverlayBottomRight, 0.5)
+	for size := range 100 {
+		c.Compose(item, size+1, 1)
+	}
+
+	if len(item.composites) > maxComposites {
+		t.Errorf("%d images cached, want at most %d", len(item.composites), maxComposites)
+	}
+}
@@ -0,0 +1,295 @@
+package systray
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DesktopEntry represents an application described by a [Desktop Entry] file.
+//
+// [Desktop Entry]: https://specifications.freedesktop.org/desktop-entry-spec/latest/
+type DesktopEntry struct {
+	// Desktop file ID, e.g. org.mozilla.firefox for
+	// applications/org.mozilla.firefox.desktop.
+	ID string
+
+	// Absolute path to the desktop file.
+	Path string
+
+	// Specific name of the application.
+	Name string
+
+	// Icon of the application, either a [Freedesktop-compliant] icon name or
+	// an absolute path.
+	//
+	// [Freedesktop-compliant]: https://specifications.freedesktop.org/icon-naming-spec/latest/
+	Icon string
+
+	// Program to execute, possibly with arguments and field codes.
+	Exec string
+
+	// WM class or WM name hint of the application windows.
+	StartupWMClass string
+
+	// Whether the entry should not be displayed in menus.
+	NoDisplay bool
+}
+
+// ParseDesktopEntry parses desktop file at path. ID of the returned entry is
+// the base name of the file without the .desktop extension.
+//
+// Only the [Desktop Entry] group is parsed, localized keys are ignored.
+func ParseDesktopEntry(path string) (*DesktopEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("desktop entry: %w", err)
+	}
+	defer file.Close()
+
+	entry := &DesktopEntry{
+		ID:   strings.TrimSuffix(filepath.Base(path), ".desktop"),
+		Path: path,
+	}
+
+	inGroup := false
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			inGroup = line == "[Desktop Entry]"
+			continue
+		}
+
+		if !inGroup {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "Name":
+			entry.Name = value
+		case "Icon":
+			entry.Icon = value
+		case "Exec":
+			entry.Exec = value
+		case "StartupWMClass":
+			entry.StartupWMClass = value
+		case "NoDisplay":
+			entry.NoDisplay = value == "true"
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("desktop entry: %w", err)
+	}
+
+	return entry, nil
+}
+
+// Program returns base name of the program specified in the Exec key, e.g.
+// "firefox" for "/usr/bin/firefox %u". Leading env invocations and environment
+// variable assignments are skipped.
+func (entry *DesktopEntry) Program() string {
+	for field := range strings.FieldsSeq(entry.Exec) {
+		field = strings.Trim(field, `"'`)
+
+		if field == "env" || strings.Contains(field, "=") {
+			continue
+		}
+
+		return filepath.Base(field)
+	}
+
+	return ""
+}
+
+// DesktopEntryResolver finds desktop entries of applications in XDG
+// application directories.
+//
+// Directory listings are loaded on first use and cached. Use
+// [DesktopEntryResolver.Reload] to pick up installed or removed applications.
+type DesktopEntryResolver struct {
+	dirs    []string
+	mu      sync.Mutex
+	loaded  bool
+	entries []*DesktopEntry
+}
+
+// NewDesktopEntryResolver returns a new [DesktopEntryResolver] that searches
+// the given application directories in order of preference. If no directories
+// are specified, applications subdirectories of $XDG_DATA_HOME and
+// $XDG_DATA_DIRS are used.
+func NewDesktopEntryResolver(dirs ...string) *DesktopEntryResolver {
+	if len(dirs) == 0 {
+		for _, dir := range xdgDataDirs() {
+			dirs = append(dirs, filepath.Join(dir, "applications"))
+		}
+	}
+
+	return &DesktopEntryResolver{
+		dirs: dirs,
+	}
+}
+
+// defaultDesktopEntryResolver is used by [Item.DesktopEntry].
+var defaultDesktopEntryResolver = NewDesktopEntryResolver()
+
+// Reload discards cached directory listings.
+func (r *DesktopEntryResolver) Reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.loaded = false
+	r.entries = nil
+}
+
+// Entries returns all desktop entries found in application directories. If
+// the same desktop file ID is present in multiple directories, the entry from
+// the most preferred directory is returned.
+func (r *DesktopEntryResolver) Entries() []*DesktopEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.load()
+
+	return r.entries
+}
+
+// Resolve returns desktop entry that matches any of the given names.
+//
+// Names are typically item ID, executable name, or window class. They are
+// compared case-insensitively against, in order of priority:
+//   - desktop file ID, or its last component for reverse-DNS IDs;
+//   - StartupWMClass;
+//   - program specified in the Exec key.
+//
+// If no entry matches, nil and false are returned.
+func (r *DesktopEntryResolver) Resolve(names ...string) (*DesktopEntry, bool) {
+	entries := r.Entries()
+
+	matchers := []func(entry *DesktopEntry, name string) bool{
+		func(entry *DesktopEntry, name string) bool {
+			id := strings.ToLower(entry.ID)
+			_, last, _ := cutLast(id, ".")
+			return id == name || last == name
+		},
+		func(entry *DesktopEntry, name string) bool {
+			return strings.ToLower(entry.StartupWMClass) == name
+		},
+		func(entry *DesktopEntry, name string) bool {
+			return strings.ToLower(entry.Program()) == name
+		},
+	}
+
+	for _, match := range matchers {
+		for _, name := range names {
+			name = strings.ToLower(filepath.Base(name))
+			if name == "" || name == "." || name == "/" {
+				continue
+			}
+
+			for _, entry := range entries {
+				if match(entry, name) {
+					return entry, true
+				}
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// load scans application directories if they were not scanned yet.
+//
+// The caller must hold r.mu.
+func (r *DesktopEntryResolver) load() {
+	if r.loaded {
+		return
+	}
+
+	seen := make(map[string]bool)
+
+	for _, dir := range r.dirs {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(path, ".desktop") {
+				return nil
+			}
+
+			// Desktop file ID of applications/kde/foo.desktop is kde-foo.
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return nil
+			}
+
+			id := strings.ReplaceAll(strings.TrimSuffix(rel, ".desktop"), string(filepath.Separator), "-")
+			if seen[id] {
+				return nil
+			}
+
+			entry, err := ParseDesktopEntry(path)
+			if err != nil {
+				return nil
+			}
+
+			entry.ID = id
+			seen[id] = true
+			r.entries = append(r.entries, entry)
+
+			return nil
+		})
+	}
+
+	r.loaded = true
+}
+
+// DesktopEntry returns desktop entry of the application that owns the item.
+// The entry is matched against ID of the item and executable of its process.
+//
+// If no entry matches, an error is returned.
+func (item *Item) DesktopEntry() (*DesktopEntry, error) {
+	names := []string{item.ID}
+
+	if process, err := item.Process(); err == nil {
+		names = append(names, process.Executable)
+
+		if len(process.Cmdline) > 0 {
+			names = append(names, process.Cmdline[0])
+		}
+	}
+
+	entry, ok := defaultDesktopEntryResolver.Resolve(names...)
+	if !ok {
+		return nil, fmt.Errorf("desktop entry: no entry matches item %s", item.ID)
+	}
+
+	return entry, nil
+}
+
+// cutLast slices s around the last instance of sep. If sep is not found,
+// cutLast returns "", s, false.
+func cutLast(s, sep string) (string, string, bool) {
+	idx := strings.LastIndex(s, sep)
+	if idx < 0 {
+		return "", s, false
+	}
+
+	return s[:idx], s[idx+len(sep):], true
+}
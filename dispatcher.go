@@ -0,0 +1,194 @@
+package systray
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// signalKey identifies source of D-Bus signals. Empty sender matches any
+// sender.
+type signalKey struct {
+	sender string
+	path   dbus.ObjectPath
+	iface  string
+}
+
+// signalDispatcher routes D-Bus signals received on a connection to handlers
+// registered for their source.
+//
+// godbus copies every incoming signal to every channel registered with
+// [dbus.Conn.Signal]. Instead of registering a channel per [Host], [Item], and
+// [Menu], all of them share a single dispatcher per connection, so that each
+// signal is received once and delivered only to the handler it is meant for.
+//
+// Every handler has its own unbounded queue, drained by a separate goroutine.
+// Signals of the same source are thus handled in order, while a handler that
+// blocks, e.g. on a D-Bus call to an unresponsive application or on a user
+// callback, never delays signals of other sources.
+type signalDispatcher struct {
+	conn     *dbus.Conn
+	signals  chan *dbus.Signal
+	mu       sync.RWMutex
+	refs     int
+	handlers map[signalKey][]*signalHandler
+}
+
+// signalHandler is a handler registered in [signalDispatcher] along with the
+// queue of signals pending for it.
+type signalHandler struct {
+	key    signalKey
+	handle func(*dbus.Signal)
+
+	mu      sync.Mutex
+	queue   []*dbus.Signal
+	running bool
+}
+
+// enqueue adds signal to the queue of the handler, starting a goroutine that
+// drains the queue if there is none. It never blocks.
+func (h *signalHandler) enqueue(signal *dbus.Signal) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.queue = append(h.queue, signal)
+
+	if !h.running {
+		h.running = true
+		go h.drain()
+	}
+}
+
+// drain handles queued signals in order until the queue is empty.
+func (h *signalHandler) drain() {
+	for {
+		h.mu.Lock()
+		if len(h.queue) == 0 {
+			h.running = false
+			h.queue = nil
+			h.mu.Unlock()
+			return
+		}
+		signal := h.queue[0]
+		h.queue[0] = nil
+		h.queue = h.queue[1:]
+		h.mu.Unlock()
+
+		h.handle(signal)
+	}
+}
+
+var (
+	dispatchersMu sync.Mutex
+	dispatchers   = make(map[*dbus.Conn]*signalDispatcher)
+)
+
+// acquireDispatcher returns dispatcher of the connection, creating it if
+// necessary. Every call must be paired with [signalDispatcher.release].
+func acquireDispatcher(conn *dbus.Conn) *signalDispatcher {
+	dispatchersMu.Lock()
+	defer dispatchersMu.Unlock()
+
+	d, exists := dispatchers[conn]
+	if !exists {
+		d = &signalDispatcher{
+			conn:     conn,
+			signals:  make(chan *dbus.Signal, 256),
+			handlers: make(map[signalKey][]*signalHandler),
+		}
+
+		conn.Signal(d.signals)
+		go d.run()
+
+		dispatchers[conn] = d
+	}
+
+	d.refs++
+
+	return d
+}
+
+// release releases reference to the dispatcher. Once all references are
+// released, dispatcher stops receiving signals.
+func (d *signalDispatcher) release() {
+	dispatchersMu.Lock()
+	defer dispatchersMu.Unlock()
+
+	d.refs--
+	if d.refs > 0 {
+		return
+	}
+
+	delete(dispatchers, d.conn)
+
+	d.conn.RemoveSignal(d.signals)
+	close(d.signals)
+}
+
+// register adds handler for signals with the given sender, object path, and
+// interface. Empty sender matches signals from any sender. Several handlers
+// may be registered for the same source, e.g. by two [Menu] instances of the
+// same item.
+//
+// The returned handler must be passed to [signalDispatcher.unregister].
+func (d *signalDispatcher) register(sender string, path dbus.ObjectPath, iface string, handler func(*dbus.Signal)) *signalHandler {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	h := &signalHandler{
+		key:    signalKey{sender, path, iface},
+		handle: handler,
+	}
+
+	d.handlers[h.key] = append(d.handlers[h.key], h)
+
+	return h
+}
+
+// unregister removes handler returned by [signalDispatcher.register]. Other
+// handlers of the same source are kept.
+func (d *signalDispatcher) unregister(h *signalHandler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	handlers := slices.DeleteFunc(d.handlers[h.key], func(other *signalHandler) bool {
+		return other == h
+	})
+
+	if len(handlers) == 0 {
+		delete(d.handlers, h.key)
+	} else {
+		d.handlers[h.key] = handlers
+	}
+}
+
+// run queues received signals for their handlers. It never blocks on the
+// handlers themselves.
+func (d *signalDispatcher) run() {
+	for signal := range d.signals {
+		for _, handler := range d.handlersOf(signal) {
+			handler.enqueue(signal)
+		}
+	}
+}
+
+// handlersOf returns handlers of the signal: handlers registered for its
+// sender, followed by handlers registered for any sender.
+func (d *signalDispatcher) handlersOf(signal *dbus.Signal) []*signalHandler {
+	iface, _, ok := cutLast(signal.Name, ".")
+	if !ok {
+		return nil
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	handlers := slices.Clone(d.handlers[signalKey{signal.Sender, signal.Path, iface}])
+
+	if signal.Sender != "" {
+		handlers = append(handlers, d.handlers[signalKey{"", signal.Path, iface}]...)
+	}
+
+	return handlers
+}
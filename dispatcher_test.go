@@ -0,0 +1,100 @@
+package systray
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newTestItems exports n fake items and returns them along with [Item]
+// instances created on a separate host connection.
+func newTestItems(tb testing.TB, address string, n int) ([]*fakeItem, []*Item) {
+	tb.Helper()
+
+	conn := connectTestBus(tb, address)
+
+	fakes := make([]*fakeItem, n)
+	items := make([]*Item, n)
+
+	for i := range n {
+		fakes[i] = newFakeItem(tb, address)
+
+		item, err := NewItem(conn, fakes[i].name())
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		item.SetCoalesceWindow(0)
+		tb.Cleanup(item.close)
+
+		items[i] = item
+	}
+
+	return fakes, items
+}
+
+func TestSlowItemDoesNotStallDispatcher(t *testing.T) {
+	address := startTestBus(t)
+	fakes, items := newTestItems(t, address, 2)
+	slow, fast := fakes[0], fakes[1]
+
+	updated := make(chan string, 1)
+	items[1].OnUpdate(func() {
+		updated <- items[1].Title
+	})
+
+	slow.mu.Lock()
+	slow.delay = 2 * time.Second
+	slow.mu.Unlock()
+
+	// Refetch of the slow item is in progress while it announces another
+	// change.
+	if err := slow.emit("NewTitle"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if err := slow.emitPropertiesChanged("Title", "Slow"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fast.emitPropertiesChanged("Title", "Fast"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case title := <-updated:
+		if title != "Fast" {
+			t.Errorf("Title = %q, want %q", title, "Fast")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("update of the fast item was blocked by the slow item")
+	}
+}
+
+func BenchmarkSignalFanOut(b *testing.B) {
+	for _, n := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("items=%d", n), func(b *testing.B) {
+			address := startTestBus(b)
+			fakes, items := newTestItems(b, address, n)
+
+			updated := make(chan struct{}, n)
+			for _, item := range items {
+				item.OnUpdate(func() { updated <- struct{}{} })
+			}
+
+			b.ResetTimer()
+
+			for i := range b.N {
+				for _, fake := range fakes {
+					if err := fake.emitPropertiesChanged("Title", fmt.Sprint(i)); err != nil {
+						b.Fatal(err)
+					}
+				}
+
+				for range n {
+					<-updated
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package systray
+
+import "fmt"
+
+// ItemError describes an error that occurred while communicating with [Item],
+// e.g. when property of the item cannot be retrieved or decoded.
+type ItemError struct {
+	// Item that caused the error. Nil if the item could not be created.
+	Item *Item
+
+	// Name of the StatusNotifierItem property, if the error is related to a
+	// property.
+	Property string
+
+	// Operation that failed, such as "Get", "GetAll", "Decode", "Register", or
+	// name of the called method.
+	Op string
+
+	// Underlying error.
+	Err error
+}
+
+// Error implements error.
+func (e *ItemError) Error() string {
+	name := "item"
+	if e.Item != nil {
+		name = "item " + e.Item.uniqueName
+	}
+
+	if e.Property != "" {
+		return fmt.Sprintf("%s: %s %s: %v", name, e.Op, e.Property, e.Err)
+	}
+
+	return fmt.Sprintf("%s: %s: %v", name, e.Op, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// OnError registers callback that runs whenever an error occurs while
+// retrieving properties of the item or communicating with it in the
+// background.
+//
+// Errors that occurred while the item was being initialized are delivered to
+// the callback immediately.
+func (item *Item) OnError(callback func(err *ItemError)) {
+	item.mu.Lock()
+	item.onError = callback
+	initErrors := item.initErrors
+	item.mu.Unlock()
+
+	for _, err := range initErrors {
+		callback(err)
+	}
+}
+
+// listenErrors registers internal callback that runs whenever an error
+// occurs. It returns errors that occurred while the item was being
+// initialized.
+func (item *Item) listenErrors(listener func(*ItemError)) []*ItemError {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	item.errorListeners = append(item.errorListeners, listener)
+
+	return item.initErrors
+}
+
+// reportError delivers error to error callbacks of the item.
+func (item *Item) reportError(op, property string, err error) {
+	itemErr := &ItemError{
+		Item:     item,
+		Property: property,
+		Op:       op,
+		Err:      err,
+	}
+
+	item.mu.Lock()
+	if !item.initialized {
+		item.initErrors = append(item.initErrors, itemErr)
+	}
+	onError := item.onError
+	listeners := item.errorListeners
+	item.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(itemErr)
+	}
+
+	if onError != nil {
+		onError(itemErr)
+	}
+}
@@ -0,0 +1,377 @@
+package systray
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// IconExporter writes icons of items registered by [Host] as PNG files, for
+// bars that take icon file paths, such as Waybar, eww, or polybar scripts.
+//
+// Icons are composited with [Compositor]. File names are derived from content
+// of the icons, so items with identical icons share a single file, and paths
+// remain stable while icons are unchanged. Files are written atomically
+// whenever icons of an item change, and removed when no registered item uses
+// them.
+//
+// Each exporter writes to its own subdirectory of the export directory, so
+// that exporters of different hosts or processes never remove files of each
+// other. Subdirectories left by processes that are no longer running, e.g.
+// after a crash, are removed when a new exporter is created.
+type IconExporter struct {
+	dir        string
+	compositor *Compositor
+	size       int
+	scale      float64
+
+	// Removes callbacks registered in the host.
+	unlisten func()
+
+	mu        sync.Mutex
+	closed    bool
+	paths     map[*Item]string
+	refs      map[string]int
+	unlistens map[*Item]func()
+	onExport  func(item *Item, path string)
+}
+
+// DefaultIconExportDir returns directory where [IconExporter] creates its
+// subdirectory by default: $XDG_RUNTIME_DIR/systray, or systray-<uid> in the
+// temporary directory if XDG_RUNTIME_DIR is not set.
+func DefaultIconExportDir() string {
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); filepath.IsAbs(runtimeDir) {
+		return filepath.Join(runtimeDir, "systray")
+	}
+
+	return filepath.Join(os.TempDir(), fmt.Sprintf("systray-%d", os.Getuid()))
+}
+
+// NewIconExporter returns a new [IconExporter] that writes icons of items
+// registered by the host to a new subdirectory of dir. Icons are composited
+// with the compositor to fit into a square of size logical pixels at the given
+// scale factor.
+//
+// If dir is empty, [DefaultIconExportDir] is used. The default directory must
+// be owned by the current user and inaccessible by other users, otherwise an
+// error is returned. If compositor is nil, icons are composited with default
+// theme and overlay placement.
+func NewIconExporter(host *Host, dir string, compositor *Compositor, size int, scale float64) (*IconExporter, error) {
+	if compositor == nil {
+		compositor = NewCompositor(nil, OverlayBottomRight, DefaultOverlayScale)
+	}
+
+	var err error
+
+	if dir == "" {
+		dir = DefaultIconExportDir()
+		err = ensurePrivateDir(dir)
+	} else {
+		err = os.MkdirAll(dir, 0o700)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("icon exporter: %w", err)
+	}
+
+	removeStaleExportDirs(dir)
+
+	exportDir, err := os.MkdirTemp(dir, fmt.Sprintf("%d-", os.Getpid()))
+	if err != nil {
+		return nil, fmt.Errorf("icon exporter: %w", err)
+	}
+
+	e := &IconExporter{
+		dir:        exportDir,
+		compositor: compositor,
+		size:       size,
+		scale:      scale,
+		paths:      make(map[*Item]string),
+		refs:       make(map[string]int),
+		unlistens:  make(map[*Item]func()),
+		onExport:   func(*Item, string) {},
+	}
+
+	items, unlisten := host.listen(e.watch, e.unwatch)
+	e.unlisten = unlisten
+
+	for _, item := range items {
+		e.watch(item)
+	}
+
+	return e, nil
+}
+
+// Dir returns directory where icons are written. The directory is unique to
+// the exporter and removed by [IconExporter.Close].
+func (e *IconExporter) Dir() string {
+	return e.dir
+}
+
+// Path returns path to the current icon file of the item, or empty string if
+// the item has no icon or it was not exported.
+func (e *IconExporter) Path(item *Item) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.paths[item]
+}
+
+// OnExport sets callback that runs whenever path to the icon file of an item
+// changes. Path is empty if the item no longer has an icon.
+func (e *IconExporter) OnExport(callback func(item *Item, path string)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.onExport = callback
+}
+
+// Close removes all exported files and directory of the exporter, as well as
+// callbacks registered in the host and its items. Exporter cannot be reused
+// after Close was called.
+func (e *IconExporter) Close() error {
+	// Host runs its callbacks with its lock held, which then take e.mu.
+	e.unlisten()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, unlisten := range e.unlistens {
+		unlisten()
+	}
+
+	var firstErr error
+
+	for path := range e.refs {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if err := os.Remove(e.dir); err != nil && !os.IsNotExist(err) && firstErr == nil {
+		firstErr = err
+	}
+
+	clear(e.paths)
+	clear(e.refs)
+	clear(e.unlistens)
+	e.closed = true
+	e.onExport = nil
+
+	return firstErr
+}
+
+// removeStaleExportDirs removes subdirectories of dir named <pid>-* that were
+// created by exporters of processes that are no longer running. Errors are
+// ignored, since stale directories only waste space.
+func removeStaleExportDirs(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		prefix, _, found := strings.Cut(entry.Name(), "-")
+		if !found || !entry.IsDir() {
+			continue
+		}
+
+		pid, err := strconv.Atoi(prefix)
+		if err != nil || pid <= 0 || pid == os.Getpid() || isProcessRunning(pid) {
+			continue
+		}
+
+		os.RemoveAll(filepath.Join(dir, entry.Name()))
+	}
+}
+
+// ensurePrivateDir creates directory accessible only by the current user, or
+// verifies that the existing directory is such. Symbolic links are rejected,
+// so that another user cannot redirect exported files.
+func ensurePrivateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !info.IsDir():
+		return fmt.Errorf("%s is not a directory", dir)
+	case info.Mode().Perm()&0o077 != 0:
+		return fmt.Errorf("%s is accessible by other users", dir)
+	case !isOwnedByCurrentUser(info):
+		return fmt.Errorf("%s is owned by another user", dir)
+	}
+
+	return nil
+}
+
+// watch exports icon of the item, and exports it again whenever icons of the
+// item change.
+func (e *IconExporter) watch(item *Item) {
+	unlisten := item.listen(func(update *ItemUpdate) {
+		if update.Fields&iconFields != 0 {
+			e.export(item)
+		}
+	})
+
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		unlisten()
+		return
+	}
+	if previous, exists := e.unlistens[item]; exists {
+		previous()
+	}
+	e.unlistens[item] = unlisten
+	e.mu.Unlock()
+
+	e.export(item)
+}
+
+// unwatch releases icon file of the unregistered item and removes the callback
+// registered by watch.
+func (e *IconExporter) unwatch(item *Item) {
+	e.mu.Lock()
+	unlisten := e.unlistens[item]
+	delete(e.unlistens, item)
+	e.mu.Unlock()
+
+	if unlisten != nil {
+		unlisten()
+	}
+
+	e.remove(item)
+}
+
+// export writes current icon of the item and releases its previous file.
+func (e *IconExporter) export(item *Item) {
+	// The lock is held while the file is written, so that it cannot be
+	// released by another item in the meantime.
+	e.mu.Lock()
+
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+
+	path, err := e.write(item)
+	if err != nil {
+		e.mu.Unlock()
+		item.reportError("Export", "", err)
+		return
+	}
+
+	previous, exists := e.paths[item]
+	if exists && previous == path {
+		e.mu.Unlock()
+		return
+	}
+
+	if path != "" {
+		e.paths[item] = path
+		e.refs[path]++
+	} else {
+		delete(e.paths, item)
+	}
+
+	if exists {
+		e.release(previous)
+	}
+
+	onExport := e.onExport
+	e.mu.Unlock()
+
+	if onExport != nil {
+		onExport(item, path)
+	}
+}
+
+// remove releases icon file of the item.
+func (e *IconExporter) remove(item *Item) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if path, exists := e.paths[item]; exists {
+		delete(e.paths, item)
+		e.release(path)
+	}
+}
+
+// release decrements number of references to the file, removing the file once
+// it is no longer referenced.
+//
+// The caller must hold e.mu.
+func (e *IconExporter) release(path string) {
+	e.refs[path]--
+	if e.refs[path] > 0 {
+		return
+	}
+
+	delete(e.refs, path)
+	os.Remove(path)
+}
+
+// write writes composited icon of the item to a file named after its content,
+// unless such file already exists. It returns path to the file, or empty
+// string if the item has no icon.
+//
+// The caller must hold e.mu.
+func (e *IconExporter) write(item *Item) (string, error) {
+	img := e.compositor.Compose(item, e.size, e.scale)
+	if img == nil {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+
+	if err := png.Encode(&buf, img); err != nil {
+		return "", fmt.Errorf("encode icon: %w", err)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	path := filepath.Join(e.dir, hex.EncodeToString(sum[:16])+".png")
+
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	// The file is written under a temporary name and renamed, so that readers
+	// never observe a partially written file.
+	tmp, err := os.CreateTemp(e.dir, ".icon-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("write icon: %w", err)
+	}
+
+	_, err = tmp.Write(buf.Bytes())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("write icon: %w", err)
+	}
+
+	return path, nil
+}
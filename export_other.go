@@ -0,0 +1,18 @@
+//go:build !unix
+
+package systray
+
+import "os"
+
+// isOwnedByCurrentUser reports whether the file is owned by the current user.
+// Ownership cannot be checked on this platform, so that files are assumed to
+// be owned by the current user.
+func isOwnedByCurrentUser(info os.FileInfo) bool {
+	return true
+}
+
+// isProcessRunning reports whether process with the given ID exists. Processes
+// cannot be checked on this platform, so that they are assumed to be running.
+func isProcessRunning(pid int) bool {
+	return true
+}
@@ -0,0 +1,231 @@
+package systray
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testPixmap is a D-Bus pixmap with the wire format of IconPixmap.
+type testPixmap struct {
+	Width  int32
+	Height int32
+	Bytes  []byte
+}
+
+// solidTestPixmap returns opaque ARGB32 pixmap of the given size and color.
+func solidTestPixmap(size int32, r, g, b byte) []testPixmap {
+	pixels := make([]byte, 0, size*size*4)
+	for range size * size {
+		pixels = append(pixels, 0xff, r, g, b)
+	}
+
+	return []testPixmap{{size, size, pixels}}
+}
+
+func TestIconExporterDir(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := NewIconExporter(NewHost(nil, 1), dir, nil, 24, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := NewIconExporter(NewHost(nil, 2), dir, nil, 24, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Dir() == second.Dir() {
+		t.Fatalf("exporters share directory %s", first.Dir())
+	}
+
+	icon := filepath.Join(second.Dir(), "icon.png")
+	if err := os.WriteFile(icon, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(first.Dir()); !os.IsNotExist(err) {
+		t.Errorf("directory of closed exporter exists: %v", err)
+	}
+
+	if _, err := os.Stat(icon); err != nil {
+		t.Errorf("file of another exporter was removed: %v", err)
+	}
+}
+
+func TestEnsurePrivateDir(t *testing.T) {
+	base := t.TempDir()
+
+	private := filepath.Join(base, "private")
+	if err := ensurePrivateDir(private); err != nil {
+		t.Errorf("new directory: %v", err)
+	}
+
+	shared := filepath.Join(base, "shared")
+	if err := os.Mkdir(shared, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(shared, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensurePrivateDir(shared); err == nil {
+		t.Error("directory accessible by other users was accepted")
+	}
+
+	link := filepath.Join(base, "link")
+	if err := os.Symlink(private, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensurePrivateDir(link); err == nil {
+		t.Error("symbolic link was accepted")
+	}
+}
+
+func TestIconExporterCloseRemovesListeners(t *testing.T) {
+	address := startTestBus(t)
+	h := NewHost(connectTestBus(t, address), 1)
+
+	item := addTestItem(t, h, newFakeItem(t, address))
+	baseline := len(item.listeners)
+
+	e, err := NewIconExporter(h, t.TempDir(), nil, 24, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(h.listeners) != 1 || len(item.listeners) != baseline+1 {
+		t.Fatalf("exporter registered %d host and %d item listeners, want 1 and 1",
+			len(h.listeners), len(item.listeners)-baseline)
+	}
+
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(h.listeners) != 0 {
+		t.Errorf("%d host listeners left after Close", len(h.listeners))
+	}
+
+	if len(item.listeners) != baseline {
+		t.Errorf("%d item listeners left after Close", len(item.listeners)-baseline)
+	}
+
+	added := addTestItem(t, h, newFakeItem(t, address))
+	if len(added.listeners) != baseline {
+		t.Errorf("closed exporter registered %d listeners of a new item", len(added.listeners)-baseline)
+	}
+}
+
+func TestIconExporterRemovesStaleDirs(t *testing.T) {
+	dir := t.TempDir()
+
+	// ID of a process that has exited.
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	stale := filepath.Join(dir, fmt.Sprintf("%d-stale", cmd.Process.Pid))
+	running := filepath.Join(dir, fmt.Sprintf("%d-running", os.Getppid()))
+	other := filepath.Join(dir, "other")
+
+	for _, path := range []string{stale, running, other} {
+		writeTestFiles(t, path, map[string]string{"icon.png": ""})
+	}
+
+	e, err := NewIconExporter(NewHost(nil, 1), dir, nil, 24, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("directory of exited process exists: %v", err)
+	}
+
+	for _, path := range []string{running, other} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("directory %s was removed: %v", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestIconExporterExport(t *testing.T) {
+	address := startTestBus(t)
+	h := NewHost(connectTestBus(t, address), 1)
+
+	fake := newFakeItem(t, address)
+	fake.set("IconName", "")
+	fake.set("IconPixmap", solidTestPixmap(24, 0xff, 0, 0))
+
+	item := addTestItem(t, h, fake)
+	item.SetCoalesceWindow(0)
+
+	e, err := NewIconExporter(h, t.TempDir(), nil, 24, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	exported := make(chan string, 1)
+	e.OnExport(func(_ *Item, path string) { exported <- path })
+
+	first := e.Path(item)
+	if first == "" {
+		t.Fatal("icon was not exported")
+	}
+
+	// File is named after its content.
+	content, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(content)
+	if want := hex.EncodeToString(sum[:16]) + ".png"; filepath.Base(first) != want {
+		t.Errorf("file name = %s, want %s", filepath.Base(first), want)
+	}
+
+	fake.set("IconPixmap", solidTestPixmap(24, 0, 0, 0xff))
+	if err := fake.emit("NewIcon"); err != nil {
+		t.Fatal(err)
+	}
+
+	var second string
+
+	select {
+	case second = <-exported:
+	case <-time.After(5 * time.Second):
+		t.Fatal("icon was not exported after change")
+	}
+
+	if second == "" || second == first || e.Path(item) != second {
+		t.Fatalf("path after change = %s, want new file", second)
+	}
+
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("previous file exists: %v", err)
+	}
+
+	h.mu.Lock()
+	h.removeItem(item)
+	h.mu.Unlock()
+
+	if _, err := os.Stat(second); !os.IsNotExist(err) {
+		t.Errorf("file of unregistered item exists: %v", err)
+	}
+
+	if path := e.Path(item); path != "" {
+		t.Errorf("Path of unregistered item = %s, want empty", path)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package systray
+
+import (
+	"os"
+	"syscall"
+)
+
+// isOwnedByCurrentUser reports whether the file is owned by the current user.
+func isOwnedByCurrentUser(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+
+	return ok && int(stat.Uid) == os.Getuid()
+}
+
+// isProcessRunning reports whether process with the given ID exists.
+func isProcessRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+
+	return err == nil || err == syscall.EPERM
+}
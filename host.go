@@ -2,7 +2,10 @@ package systray
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 )
@@ -17,8 +20,27 @@ type Host struct {
 	closed       bool
 	conn         *dbus.Conn
 	items        map[string]*Item
-	signals      chan *dbus.Signal
+	keys         map[string]string
+	windows      map[uint32]*Item
+	pids         map[uint32][]*Item
+	states       map[string]*itemState
+	order        []string
+	stateTTL     time.Duration
+	dispatcher   *signalDispatcher
+	handler      *signalHandler
 	mu           sync.RWMutex
+	onRegister   func(item *Item)
+	onReregister func(item *Item)
+	onUnregister func(item *Item)
+	onAttention  func(item *Item)
+	onError      func(err *ItemError)
+
+	// Internal registration callbacks, e.g. of [Animator].
+	listeners []*hostListener
+}
+
+// hostListener receives registration events of [Host] items.
+type hostListener struct {
 	onRegister   func(item *Item)
 	onUnregister func(item *Item)
 }
@@ -32,9 +54,15 @@ func NewHost(conn *dbus.Conn, id any) *Host {
 		closed:       false,
 		conn:         conn,
 		items:        make(map[string]*Item),
-		signals:      make(chan *dbus.Signal, 64),
+		keys:         make(map[string]string),
+		windows:      make(map[uint32]*Item),
+		pids:         make(map[uint32][]*Item),
+		states:       make(map[string]*itemState),
+		stateTTL:     DefaultItemStateTTL,
 		onRegister:   func(*Item) {},
 		onUnregister: func(*Item) {},
+		onAttention:  func(*Item) {},
+		onError:      func(*ItemError) {},
 	}
 
 	return h
@@ -99,46 +127,58 @@ func (h *Host) Close() error {
 		return err
 	}
 
-	if err := h.conn.RemoveMatchSignal(
-		dbus.WithMatchInterface("org.kde.StatusNotifierWatcher"),
-		dbus.WithMatchMember("StatusNotifierItemRegistered"),
-	); err != nil {
-		return err
+	for _, rule := range h.matchRules() {
+		if err := h.conn.RemoveMatchSignal(rule...); err != nil {
+			return err
+		}
 	}
 
-	if err := h.conn.RemoveMatchSignal(
-		dbus.WithMatchInterface("org.kde.StatusNotifierWatcher"),
-		dbus.WithMatchMember("StatusNotifierItemUnregistered"),
-	); err != nil {
-		return err
+	if h.dispatcher != nil {
+		h.dispatcher.unregister(h.handler)
+		h.dispatcher.release()
+		h.dispatcher = nil
 	}
 
-	h.conn.RemoveSignal(h.signals)
-	close(h.signals)
-
 	// Close all items to unregister signals from the session bus.
 	for _, item := range h.items {
+		for _, listener := range h.listeners {
+			listener.onUnregister(item)
+		}
+
 		item.close()
 	}
 
 	h.onRegister = nil
+	h.onReregister = nil
 	h.onUnregister = nil
+	h.onAttention = nil
+	h.onError = nil
+	h.listeners = nil
 	h.closed = true
 
 	return nil
 }
 
 // Items returns currently registered items.
+//
+// Items are ordered by registration time. Items that are re-registered, e.g.
+// after restart of the application, keep their position. See [Host.Move] to
+// change the position.
 func (h *Host) Items() []*Item {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	items := make([]*Item, len(h.items))
-	idx := 0
+	byKey := make(map[string]*Item, len(h.items))
+	for uniqueName, item := range h.items {
+		byKey[h.keys[uniqueName]] = item
+	}
+
+	items := make([]*Item, 0, len(h.items))
 
-	for _, item := range h.items {
-		items[idx] = item
-		idx++
+	for _, key := range h.order {
+		if item, exists := byKey[key]; exists {
+			items = append(items, item)
+		}
 	}
 
 	return items
@@ -146,6 +186,9 @@ func (h *Host) Items() []*Item {
 
 // OnRegister sets callback that runs whenever host registers a new item.
 //
+// Items that replace a previously registered item with the same [Item.Key]
+// trigger callback set by [Host.OnReregister] instead, if any.
+//
 // Graphical tray hosts should draw item representation when OnRegister
 // callback is called.
 func (h *Host) OnRegister(callback func(*Item)) {
@@ -166,6 +209,30 @@ func (h *Host) OnUnregister(callback func(*Item)) {
 	h.onUnregister = callback
 }
 
+// OnAttention sets callback that runs whenever a registered item requests
+// attention, i.e. enters [ItemStatusNeedsAttention] status or changes its
+// attention icon while in this status.
+//
+// Items whose attention request was dismissed with [Item.DismissAttention] do
+// not trigger the callback until their next status change.
+func (h *Host) OnAttention(callback func(*Item)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.onAttention = callback
+}
+
+// OnError sets callback that runs whenever an error occurs while registering
+// an item or communicating with a registered item in the background.
+//
+// See [Item.OnError] for errors of a specific item.
+func (h *Host) OnError(callback func(err *ItemError)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.onError = callback
+}
+
 // getInitialItems retrieves items that are already registered.
 func (h *Host) getInitialItems() {
 	watcherObj := h.conn.Object(StatusNotifierWatcherInterface, StatusNotifierWatcherPath)
@@ -192,11 +259,14 @@ func (h *Host) getInitialItems() {
 
 		item, err := NewItemWithObjectPath(h.conn, uniqueName, objectPath)
 		if err != nil {
+			h.onError(&ItemError{
+				Op:  "Register",
+				Err: fmt.Errorf("%s: %w", itemName, err),
+			})
 			continue
 		}
 
-		h.items[uniqueName] = item
-		h.onRegister(item)
+		h.addItem(item)
 	}
 }
 
@@ -204,34 +274,45 @@ func (h *Host) getInitialItems() {
 //   - org.kde.StatusNotifierWatcher.StatusNotifierItemRegistered
 //   - org.kde.StatusNotifierWatcher.StatusNotifierItemUnregistered
 func (h *Host) subscribe() error {
-	if err := h.conn.AddMatchSignal(
-		dbus.WithMatchInterface("org.kde.StatusNotifierWatcher"),
-		dbus.WithMatchMember("StatusNotifierItemRegistered"),
-	); err != nil {
-		return err
+	for _, rule := range h.matchRules() {
+		if err := h.conn.AddMatchSignal(rule...); err != nil {
+			return err
+		}
 	}
 
-	if err := h.conn.AddMatchSignal(
-		dbus.WithMatchInterface("org.kde.StatusNotifierWatcher"),
-		dbus.WithMatchMember("StatusNotifierItemUnregistered"),
-	); err != nil {
-		return err
-	}
+	// Dispatcher queues signals of the watcher separately from signals of
+	// items, so registration of items, which involves D-Bus calls, does not
+	// delay delivery of signals to other items.
+	h.dispatcher = acquireDispatcher(h.conn)
+	h.handler = h.dispatcher.register("", StatusNotifierWatcherPath, StatusNotifierWatcherInterface, h.handleSignal)
 
-	h.conn.Signal(h.signals)
+	return nil
+}
 
-	go func() {
-		for signal := range h.signals {
-			switch signal.Name {
-			case StatusNotifierWatcherInterface + ".StatusNotifierItemRegistered":
-				h.handleRegisteredSignal(signal)
-			case StatusNotifierWatcherInterface + ".StatusNotifierItemUnregistered":
-				h.handleUnregisteredSignal(signal)
-			}
-		}
-	}()
+// handleSignal handles signals of the watcher.
+func (h *Host) handleSignal(signal *dbus.Signal) {
+	switch signal.Name {
+	case StatusNotifierWatcherInterface + ".StatusNotifierItemRegistered":
+		h.handleRegisteredSignal(signal)
+	case StatusNotifierWatcherInterface + ".StatusNotifierItemUnregistered":
+		h.handleUnregisteredSignal(signal)
+	}
+}
 
-	return nil
+// matchRules returns match rules for signals of the watcher.
+func (h *Host) matchRules() [][]dbus.MatchOption {
+	return [][]dbus.MatchOption{
+		{
+			dbus.WithMatchInterface(StatusNotifierWatcherInterface),
+			dbus.WithMatchMember("StatusNotifierItemRegistered"),
+			dbus.WithMatchObjectPath(StatusNotifierWatcherPath),
+		},
+		{
+			dbus.WithMatchInterface(StatusNotifierWatcherInterface),
+			dbus.WithMatchMember("StatusNotifierItemUnregistered"),
+			dbus.WithMatchObjectPath(StatusNotifierWatcherPath),
+		},
+	}
 }
 
 // isRegistered reports whether name is already registered in the host.
@@ -240,12 +321,129 @@ func (h *Host) isRegistered(uniqueName string) bool {
 	return exists
 }
 
+// addItem stores item in the host and runs callbacks.
+//
+// The caller must hold h.mu.
+func (h *Host) addItem(item *Item) {
+	reregistered := h.assignKey(item)
+	h.items[item.uniqueName] = item
+	h.indexWindow(item)
+
+	item.listen(func(update *ItemUpdate) {
+		h.handleItemUpdate(item, update)
+	})
+
+	// Errors that occurred during initialization of the item.
+	for _, err := range item.listenErrors(h.handleItemError) {
+		h.onError(err)
+	}
+
+	if reregistered && h.onReregister != nil {
+		h.onReregister(item)
+	} else {
+		h.onRegister(item)
+	}
+
+	if item.NeedsAttention() {
+		h.onAttention(item)
+	}
+
+	for _, listener := range h.listeners {
+		listener.onRegister(item)
+	}
+}
+
+// removeItem runs callbacks and removes item from the host.
+//
+// The caller must hold h.mu.
+func (h *Host) removeItem(item *Item) {
+	h.onUnregister(item)
+
+	for _, listener := range h.listeners {
+		listener.onUnregister(item)
+	}
+
+	item.close()
+	delete(h.items, item.uniqueName)
+	h.unindexWindow(item)
+
+	if key, exists := h.keys[item.uniqueName]; exists {
+		delete(h.keys, item.uniqueName)
+		h.releaseKey(key)
+	}
+}
+
+// listen registers internal callbacks that run whenever host registers or
+// unregisters an item. Items that are already registered are returned along
+// with a function that removes the callbacks.
+//
+// Callbacks run with h.mu held, so the returned function must not be called
+// while holding locks that the callbacks take.
+func (h *Host) listen(onRegister, onUnregister func(item *Item)) (items []*Item, unlisten func()) {
+	listener := &hostListener{onRegister, onUnregister}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.listeners = append(h.listeners, listener)
+
+	return slices.Collect(maps.Values(h.items)), func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		h.listeners = slices.DeleteFunc(h.listeners, func(other *hostListener) bool {
+			return other == listener
+		})
+	}
+}
+
+// handleItemUpdate runs host callbacks associated with item updates.
+func (h *Host) handleItemUpdate(item *Item, update *ItemUpdate) {
+	if change := update.Change(ItemFieldWindowID); change != nil {
+		h.mu.Lock()
+		h.reindexWindow(item, change.Old.(uint32))
+		h.mu.Unlock()
+	}
+
+	attentionFields := ItemFieldStatus |
+		ItemFieldAttentionIconName |
+		ItemFieldAttentionIconPixmap |
+		ItemFieldAttentionMovieName
+
+	if update.Fields&attentionFields == 0 || !item.NeedsAttention() {
+		return
+	}
+
+	h.mu.RLock()
+	onAttention := h.onAttention
+	h.mu.RUnlock()
+
+	if onAttention != nil {
+		onAttention(item)
+	}
+}
+
+// handleItemError delivers item error to the host callback.
+func (h *Host) handleItemError(err *ItemError) {
+	h.mu.RLock()
+	onError := h.onError
+	h.mu.RUnlock()
+
+	if onError != nil {
+		onError(err)
+	}
+}
+
 // handleRegisteredSignal handles the
 // org.kde.StatusNotifierWatcher.StatusNotifierItemRegistered signal
 func (h *Host) handleRegisteredSignal(signal *dbus.Signal) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	if h.closed {
+		return
+	}
+
 	uniqueName, objectPath, err := uniqueNameAndPathFromDBusSignal(signal)
 	if err != nil {
 		return
@@ -257,11 +455,14 @@ func (h *Host) handleRegisteredSignal(signal *dbus.Signal) {
 
 	item, err := NewItemWithObjectPath(h.conn, uniqueName, objectPath)
 	if err != nil {
+		h.onError(&ItemError{
+			Op:  "Register",
+			Err: fmt.Errorf("%s%s: %w", uniqueName, objectPath, err),
+		})
 		return
 	}
 
-	h.items[item.uniqueName] = item
-	h.onRegister(item)
+	h.addItem(item)
 }
 
 // handleUnregisteredSignal handles the
@@ -270,6 +471,10 @@ func (h *Host) handleUnregisteredSignal(signal *dbus.Signal) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	if h.closed {
+		return
+	}
+
 	uniqueName, _, err := uniqueNameAndPathFromDBusSignal(signal)
 	if err != nil {
 		return
@@ -280,7 +485,5 @@ func (h *Host) handleUnregisteredSignal(signal *dbus.Signal) {
 		return
 	}
 
-	h.onUnregister(item)
-	item.close()
-	delete(h.items, uniqueName)
+	h.removeItem(item)
 }
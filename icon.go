@@ -1,11 +1,18 @@
 package systray
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"image"
+	"math"
 	"sort"
 )
 
 // Icon represents icon of the system tray item.
+//
+// Icon implements [image.Image], so that it can be drawn or encoded without
+// copying. Use [Icon.Image] to convert it to [image.NRGBA] for faster drawing.
 type Icon struct {
 	// Width of the icon.
 	Width int32
@@ -13,13 +20,99 @@ type Icon struct {
 	// Height of the icon.
 	Height int32
 
-	// ARGB32 binary representation of the icon.
+	// ARGB32 binary representation of the icon. Each pixel is represented by
+	// 4 bytes in network byte order: alpha, red, green, blue. Color
+	// components are not premultiplied by alpha.
 	Bytes []byte
 }
 
+// Equal reports whether icon and other have the same dimensions and content.
+func (icon *Icon) Equal(other *Icon) bool {
+	if icon == nil || other == nil {
+		return icon == other
+	}
+
+	return icon.Width == other.Width &&
+		icon.Height == other.Height &&
+		bytes.Equal(icon.Bytes, other.Bytes)
+}
+
 // IconSet represents a set of resolutions for an icon.
 type IconSet struct {
 	icons []*Icon
+
+	// Number of icons rejected while decoding the set.
+	rejected int
+
+	// Content hash of the set, if it is stored in [IconCache].
+	hash string
+}
+
+// Errors of pixmap validation. Use [errors.Is] to check for them.
+var (
+	// ErrInvalidPixmap indicates that pixmap does not have the expected
+	// D-Bus signature (iiay).
+	ErrInvalidPixmap = errors.New("invalid pixmap format")
+
+	// ErrInvalidDimensions indicates that width or height of pixmap is not
+	// positive.
+	ErrInvalidDimensions = errors.New("invalid pixmap dimensions")
+
+	// ErrPixmapSizeMismatch indicates that length of pixmap data does not
+	// match its dimensions.
+	ErrPixmapSizeMismatch = errors.New("pixmap data does not match dimensions")
+
+	// ErrPixmapTooLarge indicates that pixmap exceeds [IconLimits].
+	ErrPixmapTooLarge = errors.New("pixmap exceeds limits")
+)
+
+// PixmapError describes a pixmap that was rejected while decoding an
+// [IconSet].
+type PixmapError struct {
+	// Index of the pixmap in the set.
+	Index int
+
+	// Dimensions of the pixmap, if they could be decoded.
+	Width  int32
+	Height int32
+
+	// Length of pixmap data in bytes, if it could be decoded.
+	Length int
+
+	// Underlying error, one of ErrInvalidPixmap, ErrInvalidDimensions,
+	// ErrPixmapSizeMismatch, and ErrPixmapTooLarge.
+	Err error
+}
+
+// Error implements error.
+func (e *PixmapError) Error() string {
+	return fmt.Sprintf("icon %d (%dx%d, %d bytes): %v", e.Index, e.Width, e.Height, e.Length, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *PixmapError) Unwrap() error {
+	return e.Err
+}
+
+// IconLimits restricts size of decoded pixmaps. Zero fields are not limited.
+type IconLimits struct {
+	// Maximum width of a single icon in pixels.
+	MaxWidth int32
+
+	// Maximum height of a single icon in pixels.
+	MaxHeight int32
+
+	// Maximum total length of pixel data of all icons in [IconSet] in bytes.
+	MaxBytes int
+}
+
+// DefaultIconLimits are limits applied by [NewIconFromDBusPixmap] and
+// [NewIconSetFromDBusProperty], and thus to icons of every [Item]. They can
+// be changed before items are created.
+var DefaultIconLimits = IconLimits{
+	MaxWidth:  1024,
+	MaxHeight: 1024,
+	MaxBytes:  16 << 20,
 }
 
 // NewIconFromDBusPixmap returns a new [Icon] from D-Bus pixmap.
@@ -32,32 +125,85 @@ type IconSet struct {
 //   - <width>: width of the icon (int32)
 //   - <height>: height of the icon (int32)
 //   - <bytes>: content of the icon ([]byte)
+//
+// Pixmap is validated with [DefaultIconLimits]. The returned error is
+// [PixmapError] if pixmap is invalid.
 func NewIconFromDBusPixmap(pixmap any) (*Icon, error) {
+	return NewIconFromDBusPixmapWithLimits(pixmap, DefaultIconLimits)
+}
+
+// NewIconFromDBusPixmapWithLimits is like [NewIconFromDBusPixmap], but
+// validates pixmap with the given limits. MaxBytes of limits applies to the
+// single icon.
+func NewIconFromDBusPixmapWithLimits(pixmap any, limits IconLimits) (*Icon, error) {
+	pixmapErr := &PixmapError{}
+
 	data, ok := pixmap.([]any)
 	if !ok || len(data) != 3 {
-		return nil, fmt.Errorf("invalid pixmap format: expected a slice of 3 elements")
+		pixmapErr.Err = fmt.Errorf("%w: expected a slice of 3 elements", ErrInvalidPixmap)
+		return nil, pixmapErr
 	}
 
 	width, ok := data[0].(int32)
 	if !ok {
-		return nil, fmt.Errorf("invalid width type: expected int32")
+		pixmapErr.Err = fmt.Errorf("%w: invalid width type: expected int32", ErrInvalidPixmap)
+		return nil, pixmapErr
 	}
 
 	height, ok := data[1].(int32)
 	if !ok {
-		return nil, fmt.Errorf("invalid height type: expected int32")
+		pixmapErr.Err = fmt.Errorf("%w: invalid height type: expected int32", ErrInvalidPixmap)
+		return nil, pixmapErr
 	}
 
-	bytes, ok := data[2].([]byte)
+	pixmapErr.Width = width
+	pixmapErr.Height = height
+
+	pixels, ok := data[2].([]byte)
 	if !ok {
-		return nil, fmt.Errorf("invalid bytes format: expected []byte")
+		pixmapErr.Err = fmt.Errorf("%w: invalid bytes format: expected []byte", ErrInvalidPixmap)
+		return nil, pixmapErr
 	}
 
-	return &Icon{
+	pixmapErr.Length = len(pixels)
+
+	icon := &Icon{
 		Width:  width,
 		Height: height,
-		Bytes:  bytes,
-	}, nil
+		Bytes:  pixels,
+	}
+
+	if err := limits.validate(icon, 0); err != nil {
+		pixmapErr.Err = err
+		return nil, pixmapErr
+	}
+
+	return icon, nil
+}
+
+// validate checks dimensions of the icon against its data and limits. Parameter
+// used is the number of bytes already used by other icons of the set.
+func (l IconLimits) validate(icon *Icon, used int) error {
+	if icon.Width <= 0 || icon.Height <= 0 {
+		return ErrInvalidDimensions
+	}
+
+	if (l.MaxWidth > 0 && icon.Width > l.MaxWidth) ||
+		(l.MaxHeight > 0 && icon.Height > l.MaxHeight) {
+		return fmt.Errorf("%w: maximum dimensions are %dx%d", ErrPixmapTooLarge, l.MaxWidth, l.MaxHeight)
+	}
+
+	// Computed in int64 to avoid overflow on 32-bit platforms.
+	expected := int64(icon.Width) * int64(icon.Height) * 4
+	if int64(len(icon.Bytes)) != expected {
+		return fmt.Errorf("%w: expected %d bytes", ErrPixmapSizeMismatch, expected)
+	}
+
+	if l.MaxBytes > 0 && used+len(icon.Bytes) > l.MaxBytes {
+		return fmt.Errorf("%w: maximum total size is %d bytes", ErrPixmapTooLarge, l.MaxBytes)
+	}
+
+	return nil
 }
 
 // NewIconSetFromDBusProperty returns a new [IconSet] from value of D-Bus icon
@@ -71,34 +217,133 @@ func NewIconFromDBusPixmap(pixmap any) (*Icon, error) {
 //	[<icon>]
 //
 // See [NewIconFromDBusPixmap] for details about <icon> format.
+//
+// Icons are validated with [DefaultIconLimits]. Invalid icons are skipped. If
+// some icons are invalid, the returned set contains the remaining icons, and
+// the returned error joins [PixmapError] of each skipped icon. Use
+// [IconSet.Rejected] to get the number of skipped icons.
 func NewIconSetFromDBusProperty(value any) (*IconSet, error) {
+	return NewIconSetFromDBusPropertyWithLimits(value, DefaultIconLimits)
+}
+
+// NewIconSetFromDBusPropertyWithLimits is like [NewIconSetFromDBusProperty],
+// but validates icons with the given limits. Icons that would make the total
+// size of the set exceed MaxBytes of limits are skipped.
+func NewIconSetFromDBusPropertyWithLimits(value any, limits IconLimits) (*IconSet, error) {
 	pixmaps, ok := value.([][]any)
 	if !ok {
-		return nil, fmt.Errorf("invalid property format: expected a slice of slices")
+		return nil, fmt.Errorf("%w: expected a slice of slices", ErrInvalidPixmap)
 	}
 
+	// The total size is checked separately below.
+	iconLimits := limits
+	iconLimits.MaxBytes = 0
+
 	icons := make([]*Icon, 0, len(pixmaps))
+	errs := make([]error, 0)
+	used := 0
 
-	for _, pixmap := range pixmaps {
-		icon, err := NewIconFromDBusPixmap(pixmap)
-		if err != nil {
-			fmt.Println(err)
+	for idx, pixmap := range pixmaps {
+		icon, err := NewIconFromDBusPixmapWithLimits(pixmap, iconLimits)
+		if err == nil {
+			if err = limits.validate(icon, used); err != nil {
+				err = &PixmapError{
+					Width:  icon.Width,
+					Height: icon.Height,
+					Length: len(icon.Bytes),
+					Err:    err,
+				}
+			}
+		}
+
+		if pixmapErr, ok := err.(*PixmapError); ok {
+			pixmapErr.Index = idx
+			errs = append(errs, pixmapErr)
 			continue
 		}
 
+		used += len(icon.Bytes)
 		icons = append(icons, icon)
 	}
 
+	// Icons are ordered by their larger dimension, which determines how large
+	// the icon is when fitted into a square. Ties are broken by area.
 	sort.Slice(icons, func(i, j int) bool {
 		a := icons[i]
 		b := icons[j]
 
+		if a.size() != b.size() {
+			return a.size() < b.size()
+		}
+
 		return a.Width*a.Height < b.Width*b.Height
 	})
 
 	return &IconSet{
-		icons: icons,
-	}, nil
+		icons:    icons,
+		rejected: len(errs),
+	}, errors.Join(errs...)
+}
+
+// Rejected returns the number of icons that were skipped while decoding the
+// set, because they were invalid or exceeded [IconLimits].
+func (is *IconSet) Rejected() int {
+	if is == nil {
+		return 0
+	}
+
+	return is.rejected
+}
+
+// Best returns icon that best fits into a square of size logical pixels at the
+// given scale factor, e.g. size 22 and scale 1.5 for a 22px panel on a HiDPI
+// screen, which corresponds to 33 physical pixels.
+//
+// The smallest icon whose larger dimension is at least the target size is
+// returned, so that the icon is downscaled rather than upscaled. If there is no
+// such icon, the largest one is returned. Nil is returned for empty or nil
+// sets.
+//
+// See [IconSet.BestImage] to get the icon scaled to exactly the target size.
+func (is *IconSet) Best(size int, scale float64) *Icon {
+	if is == nil || len(is.icons) == 0 {
+		return nil
+	}
+
+	target := targetSize(size, scale)
+
+	for _, icon := range is.icons {
+		if icon.size() >= target {
+			return icon
+		}
+	}
+
+	return is.GetLargest()
+}
+
+// BestImage returns icon selected by [IconSet.Best], scaled with
+// [ScaleImage] to exactly the target size in physical pixels. Nil is returned
+// for empty sets.
+//
+// Scaled icons of sets stored in [DefaultIconCache], such as icon sets of
+// [Item], are cached. The returned image is shared and must not be modified.
+func (is *IconSet) BestImage(size int, scale float64) *image.NRGBA {
+	render := func() *image.NRGBA {
+		icon := is.Best(size, scale)
+		if icon == nil {
+			return nil
+		}
+
+		target := targetSize(size, scale)
+
+		return ScaleImage(icon, target, target)
+	}
+
+	if is == nil || is.hash == "" {
+		return render()
+	}
+
+	return DefaultIconCache.image(iconCacheKey{is.hash, size, scale}, render)
 }
 
 // GetAll returns all resolutions in the set.
@@ -123,3 +368,41 @@ func (is *IconSet) GetLargest() *Icon {
 
 	return is.icons[len(is.icons)-1]
 }
+
+// Equal reports whether is and other contain the same icons.
+func (is *IconSet) Equal(other *IconSet) bool {
+	if is == nil || other == nil || is == other {
+		return is == other
+	}
+
+	if is.hash != "" && other.hash != "" {
+		return is.hash == other.hash
+	}
+
+	if len(is.icons) != len(other.icons) {
+		return false
+	}
+
+	for idx, icon := range is.icons {
+		if !icon.Equal(other.icons[idx]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// size returns the larger dimension of the icon.
+func (icon *Icon) size() int {
+	return int(max(icon.Width, icon.Height))
+}
+
+// targetSize returns size in physical pixels from size in logical pixels and
+// scale factor.
+func targetSize(size int, scale float64) int {
+	if scale <= 0 {
+		scale = 1
+	}
+
+	return int(math.Ceil(float64(size) * scale))
+}
@@ -0,0 +1,178 @@
+package systray
+
+import (
+	"errors"
+	"testing"
+)
+
+// testPixmapProperty returns value of D-Bus icon property with transparent
+// icons of the given sizes.
+func testPixmapProperty(sizes ...int32) [][]any {
+	pixmaps := make([][]any, 0, len(sizes))
+
+	for _, size := range sizes {
+		pixmaps = append(pixmaps, []any{size, size, make([]byte, size*size*4)})
+	}
+
+	return pixmaps
+}
+
+func TestIconSetBest(t *testing.T) {
+	// Icons are listed out of order, as applications may send them.
+	set, err := NewIconSetFromDBusProperty(testPixmapProperty(48, 16, 33, 22))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		size  int
+		scale float64
+		want  int32
+	}{
+		{22, 1, 22},
+		{22, 1.5, 33},
+		{16, 2, 33},
+		{16, 1, 16},
+		{8, 1, 16},
+		{24, 1, 33},
+		{22, 0, 22},
+
+		// Sizes larger than any icon fall back to the largest one.
+		{64, 1, 48},
+		{22, 3, 48},
+	}
+
+	for _, tt := range tests {
+		icon := set.Best(tt.size, tt.scale)
+		if icon == nil || icon.Width != tt.want {
+			t.Errorf("Best(%d, %g) = %v, want %dpx icon", tt.size, tt.scale, icon, tt.want)
+		}
+	}
+
+	var empty *IconSet
+	if icon := empty.Best(22, 1); icon != nil {
+		t.Errorf("Best of nil set = %v, want nil", icon)
+	}
+
+	if img := set.BestImage(22, 1.5); img.Bounds().Dx() != 33 || img.Bounds().Dy() != 33 {
+		t.Errorf("BestImage(22, 1.5) bounds = %v, want 33x33", img.Bounds())
+	}
+}
+
+func TestNewIconSetFromDBusPropertyWithLimits(t *testing.T) {
+	unlimited := IconLimits{}
+
+	tests := []struct {
+		name    string
+		value   any
+		limits  IconLimits
+		want    []int32
+		wantErr []error
+
+		// Index of the first rejected pixmap.
+		wantIndex int
+	}{
+		{
+			name:   "valid",
+			value:  testPixmapProperty(16, 22),
+			limits: DefaultIconLimits,
+			want:   []int32{16, 22},
+		},
+		{
+			name:      "negative width",
+			value:     [][]any{{int32(-16), int32(16), []byte{}}, testPixmapProperty(22)[0]},
+			limits:    unlimited,
+			want:      []int32{22},
+			wantErr:   []error{ErrInvalidDimensions},
+			wantIndex: 0,
+		},
+		{
+			name:      "zero height",
+			value:     [][]any{testPixmapProperty(22)[0], {int32(16), int32(0), []byte{}}},
+			limits:    unlimited,
+			want:      []int32{22},
+			wantErr:   []error{ErrInvalidDimensions},
+			wantIndex: 1,
+		},
+		{
+			name:      "length mismatch",
+			value:     [][]any{{int32(16), int32(16), make([]byte, 16*16*4-1)}},
+			limits:    unlimited,
+			wantErr:   []error{ErrPixmapSizeMismatch},
+			wantIndex: 0,
+		},
+		{
+			name:      "invalid format",
+			value:     [][]any{{int32(16), "16", []byte{}}, testPixmapProperty(16)[0]},
+			limits:    unlimited,
+			want:      []int32{16},
+			wantErr:   []error{ErrInvalidPixmap},
+			wantIndex: 0,
+		},
+		{
+			name:      "maximum dimensions",
+			value:     testPixmapProperty(16, 64, 32),
+			limits:    IconLimits{MaxWidth: 32, MaxHeight: 32},
+			want:      []int32{16, 32},
+			wantErr:   []error{ErrPixmapTooLarge},
+			wantIndex: 1,
+		},
+		{
+			// The budget is spent in order of pixmaps, so that the third
+			// pixmap does not fit even though it is the smallest.
+			name:      "total size",
+			value:     testPixmapProperty(16, 8, 4),
+			limits:    IconLimits{MaxBytes: 16*16*4 + 8*8*4},
+			want:      []int32{8, 16},
+			wantErr:   []error{ErrPixmapTooLarge},
+			wantIndex: 2,
+		},
+		{
+			name:      "several errors",
+			value:     [][]any{{int32(-1), int32(1), []byte{}}, {int32(2), int32(2), []byte{}}, testPixmapProperty(64)[0]},
+			limits:    IconLimits{MaxWidth: 32},
+			wantErr:   []error{ErrInvalidDimensions, ErrPixmapSizeMismatch, ErrPixmapTooLarge},
+			wantIndex: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := NewIconSetFromDBusPropertyWithLimits(tt.value, tt.limits)
+
+			for _, wantErr := range tt.wantErr {
+				if !errors.Is(err, wantErr) {
+					t.Errorf("error = %v, want %v", err, wantErr)
+				}
+			}
+
+			if len(tt.wantErr) == 0 && err != nil {
+				t.Errorf("error = %v, want nil", err)
+			}
+
+			var pixmapErr *PixmapError
+			if len(tt.wantErr) > 0 && (!errors.As(err, &pixmapErr) || pixmapErr.Index != tt.wantIndex) {
+				t.Errorf("PixmapError = %v, want index %d", pixmapErr, tt.wantIndex)
+			}
+
+			if set.Rejected() != len(tt.wantErr) {
+				t.Errorf("Rejected() = %d, want %d", set.Rejected(), len(tt.wantErr))
+			}
+
+			icons := set.GetAll()
+			if len(icons) != len(tt.want) {
+				t.Fatalf("%d icons, want %d", len(icons), len(tt.want))
+			}
+
+			for idx, icon := range icons {
+				if icon.Width != tt.want[idx] {
+					t.Errorf("icon %d width = %d, want %d", idx, icon.Width, tt.want[idx])
+				}
+			}
+		})
+	}
+
+	if _, err := NewIconSetFromDBusPropertyWithLimits("invalid", unlimited); !errors.Is(err, ErrInvalidPixmap) {
+		t.Errorf("error = %v, want %v", err, ErrInvalidPixmap)
+	}
+}
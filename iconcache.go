@@ -0,0 +1,297 @@
+package systray
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"strings"
+	"sync"
+)
+
+// DefaultIconCacheSize is the default capacity of [IconCache] in bytes.
+const DefaultIconCacheSize = 32 << 20
+
+// missingImageBytes is the size accounted for a cached failure to render an
+// image, so that failures are evicted as well.
+const missingImageBytes = 64
+
+// DefaultIconCache is the process-wide [IconCache] shared by all items.
+//
+// Icon sets of items are stored in the cache by content, so that items with
+// identical pixmaps share a single [IconSet], and unchanged pixmaps are not
+// decoded again. Icons resolved and scaled by [Item.ResolveIcon] and
+// [IconSet.BestImage] are stored per size and scale.
+var DefaultIconCache = NewIconCache(DefaultIconCacheSize)
+
+// IconCacheStats contains counters of [IconCache].
+type IconCacheStats struct {
+	// Number of lookups that found a cached entry.
+	Hits uint64
+
+	// Number of lookups that did not find a cached entry.
+	Misses uint64
+
+	// Number of entries evicted to stay within capacity.
+	Evictions uint64
+
+	// Number of cached entries.
+	Entries int
+
+	// Total size of cached entries in bytes.
+	Bytes int
+}
+
+// iconCacheKey identifies entry of [IconCache].
+type iconCacheKey struct {
+	// Content hash of pixmaps or key of theme lookup.
+	source string
+
+	// Size and scale of scaled variants. Zero for icon sets.
+	size  int
+	scale float64
+}
+
+// iconCacheEntry is an entry of [IconCache].
+type iconCacheEntry struct {
+	key   iconCacheKey
+	value any
+	bytes int
+}
+
+// IconCache is a cache of icon sets and scaled icons with least recently used
+// eviction, bounded by the total size of cached pixel data.
+type IconCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	entries  map[iconCacheKey]*list.Element
+	lru      *list.List
+	stats    IconCacheStats
+}
+
+// NewIconCache returns a new [IconCache] that holds at most maxBytes of pixel
+// data.
+func NewIconCache(maxBytes int) *IconCache {
+	return &IconCache{
+		maxBytes: maxBytes,
+		entries:  make(map[iconCacheKey]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Stats returns counters of the cache.
+func (c *IconCache) Stats() IconCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stats
+}
+
+// SetMaxBytes changes capacity of the cache, evicting entries if necessary.
+func (c *IconCache) SetMaxBytes(maxBytes int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maxBytes = maxBytes
+	c.evict()
+}
+
+// Purge removes all entries from the cache. Counters of hits, misses, and
+// evictions are preserved.
+func (c *IconCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+	c.lru.Init()
+	c.stats.Entries = 0
+	c.stats.Bytes = 0
+}
+
+// get returns cached value for the key.
+func (c *IconCache) get(key iconCacheKey) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exists := c.entries[key]
+	if !exists {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.stats.Hits++
+	c.lru.MoveToFront(elem)
+
+	return elem.Value.(*iconCacheEntry).value, true
+}
+
+// put stores value of the given size in bytes for the key. Values larger than
+// capacity of the cache are not stored.
+func (c *IconCache) put(key iconCacheKey, value any, bytes int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if bytes > c.maxBytes {
+		return
+	}
+
+	if elem, exists := c.entries[key]; exists {
+		entry := elem.Value.(*iconCacheEntry)
+		c.stats.Bytes += bytes - entry.bytes
+		entry.value = value
+		entry.bytes = bytes
+		c.lru.MoveToFront(elem)
+	} else {
+		c.entries[key] = c.lru.PushFront(&iconCacheEntry{
+			key:   key,
+			value: value,
+			bytes: bytes,
+		})
+		c.stats.Bytes += bytes
+		c.stats.Entries++
+	}
+
+	c.evict()
+}
+
+// evict removes least recently used entries until the cache is within
+// capacity.
+//
+// The caller must hold c.mu.
+func (c *IconCache) evict() {
+	for c.stats.Bytes > c.maxBytes {
+		elem := c.lru.Back()
+		if elem == nil {
+			return
+		}
+
+		entry := c.lru.Remove(elem).(*iconCacheEntry)
+		delete(c.entries, entry.key)
+
+		c.stats.Bytes -= entry.bytes
+		c.stats.Entries--
+		c.stats.Evictions++
+	}
+}
+
+// iconSet returns icon set decoded from value of D-Bus icon property with
+// [NewIconSetFromDBusProperty]. Sets with identical content share a single
+// cached [IconSet].
+//
+// Sets with invalid icons are not cached, so that errors are reported every
+// time they are decoded.
+func (c *IconCache) iconSet(value any) (*IconSet, error) {
+	hash, ok := pixmapsHash(value)
+	if !ok {
+		return NewIconSetFromDBusProperty(value)
+	}
+
+	key := iconCacheKey{source: hash}
+
+	if cached, ok := c.get(key); ok {
+		return cached.(*IconSet), nil
+	}
+
+	iconset, err := NewIconSetFromDBusProperty(value)
+	if err != nil {
+		return iconset, err
+	}
+
+	iconset.hash = hash
+	c.put(key, iconset, iconset.bytes())
+
+	return iconset, nil
+}
+
+// image returns cached image for the key, or stores the result of render. Nil
+// results are cached as well, so that failed theme lookups are not repeated
+// until the theme is reloaded, which changes the key.
+func (c *IconCache) image(key iconCacheKey, render func() *image.NRGBA) *image.NRGBA {
+	if cached, ok := c.get(key); ok {
+		return cached.(*image.NRGBA)
+	}
+
+	img := render()
+	if img != nil {
+		c.put(key, img, len(img.Pix))
+	} else {
+		c.put(key, img, missingImageBytes)
+	}
+
+	return img
+}
+
+// pixmapsHash returns content hash of value of D-Bus icon property, or false
+// if value does not have the expected format or exceeds [DefaultIconLimits].
+//
+// Limits are checked before hashing, so that arbitrarily large pixmaps are
+// rejected without reading their data.
+func pixmapsHash(value any) (string, bool) {
+	pixmaps, ok := value.([][]any)
+	if !ok {
+		return "", false
+	}
+
+	icons := make([]*Icon, len(pixmaps))
+	used := 0
+
+	for idx, pixmap := range pixmaps {
+		if len(pixmap) != 3 {
+			return "", false
+		}
+
+		width, ok1 := pixmap[0].(int32)
+		height, ok2 := pixmap[1].(int32)
+		pixels, ok3 := pixmap[2].([]byte)
+
+		if !ok1 || !ok2 || !ok3 {
+			return "", false
+		}
+
+		icons[idx] = &Icon{Width: width, Height: height, Bytes: pixels}
+
+		if DefaultIconLimits.validate(icons[idx], used) != nil {
+			return "", false
+		}
+
+		used += len(pixels)
+	}
+
+	h := sha256.New()
+
+	for _, icon := range icons {
+		width, height, pixels := icon.Width, icon.Height, icon.Bytes
+
+		var header [16]byte
+
+		binary.BigEndian.PutUint32(header[0:], uint32(width))
+		binary.BigEndian.PutUint32(header[4:], uint32(height))
+		binary.BigEndian.PutUint64(header[8:], uint64(len(pixels)))
+
+		h.Write(header[:])
+		h.Write(pixels)
+	}
+
+	return "pixmap:" + string(h.Sum(nil)), true
+}
+
+// themeKey returns cache key of the icon resolved with the theme.
+func themeKey(theme *IconTheme, name string, extraDirs []string, size int, scale float64) iconCacheKey {
+	return iconCacheKey{
+		source: fmt.Sprintf("theme:%p:%d:%s:%s", theme, theme.currentRevision(), name, strings.Join(extraDirs, ":")),
+		size:   size,
+		scale:  scale,
+	}
+}
+
+// bytes returns total size of pixel data of the set.
+func (is *IconSet) bytes() int {
+	total := 0
+
+	for _, icon := range is.icons {
+		total += len(icon.Bytes)
+	}
+
+	return total
+}
@@ -0,0 +1,131 @@
+package systray
+
+import (
+	"image"
+	"testing"
+)
+
+// testImage returns image with pixel data of the given size in bytes.
+func testImage(bytes int) *image.NRGBA {
+	return image.NewNRGBA(image.Rect(0, 0, bytes/4, 1))
+}
+
+func TestIconCacheEviction(t *testing.T) {
+	c := NewIconCache(100)
+
+	a := iconCacheKey{source: "a"}
+	b := iconCacheKey{source: "b"}
+	d := iconCacheKey{source: "d"}
+
+	c.put(a, testImage(40), 40)
+	c.put(b, testImage(40), 40)
+
+	// Access makes a the most recently used entry.
+	if _, ok := c.get(a); !ok {
+		t.Fatal("entry a is missing")
+	}
+
+	c.put(d, testImage(40), 40)
+
+	if _, ok := c.get(b); ok {
+		t.Error("least recently used entry b was not evicted")
+	}
+
+	if _, ok := c.get(a); !ok {
+		t.Error("recently used entry a was evicted")
+	}
+
+	stats := c.Stats()
+	want := IconCacheStats{Hits: 2, Misses: 1, Evictions: 1, Entries: 2, Bytes: 80}
+	if stats != want {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+
+	// Replacing entry updates its size.
+	c.put(a, testImage(20), 20)
+	if bytes := c.Stats().Bytes; bytes != 60 {
+		t.Errorf("Bytes = %d after replace, want 60", bytes)
+	}
+
+	// Entries larger than capacity are not stored.
+	c.put(iconCacheKey{source: "large"}, testImage(200), 200)
+	if entries := c.Stats().Entries; entries != 2 {
+		t.Errorf("Entries = %d after oversized put, want 2", entries)
+	}
+
+	c.SetMaxBytes(30)
+	if stats := c.Stats(); stats.Entries != 1 || stats.Bytes != 20 {
+		t.Errorf("Stats() = %+v after shrink, want 1 entry of 20 bytes", stats)
+	}
+
+	c.Purge()
+	if stats := c.Stats(); stats.Entries != 0 || stats.Bytes != 0 || stats.Evictions != 2 {
+		t.Errorf("Stats() = %+v after purge, want empty cache with counters kept", stats)
+	}
+}
+
+func TestIconCacheImageMissing(t *testing.T) {
+	c := NewIconCache(1 << 10)
+	key := iconCacheKey{source: "missing", size: 16, scale: 1}
+
+	renders := 0
+	render := func() *image.NRGBA {
+		renders++
+		return nil
+	}
+
+	for range 3 {
+		if img := c.image(key, render); img != nil {
+			t.Fatal("image of failed render is not nil")
+		}
+	}
+
+	if renders != 1 {
+		t.Errorf("failed render repeated %d times, want 1", renders)
+	}
+
+	if stats := c.Stats(); stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("Stats() = %+v, want 2 hits and 1 miss", stats)
+	}
+}
+
+func TestIconCacheIconSet(t *testing.T) {
+	c := NewIconCache(1 << 20)
+
+	pixmaps := func() [][]any {
+		return [][]any{{int32(2), int32(2), make([]byte, 16)}}
+	}
+
+	first, err := c.iconSet(pixmaps())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := c.iconSet(pixmaps())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second {
+		t.Error("identical pixmaps were not shared")
+	}
+}
+
+func TestPixmapsHashLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		pixmaps [][]any
+		ok      bool
+	}{
+		{"valid", [][]any{{int32(1), int32(1), make([]byte, 4)}}, true},
+		{"size mismatch", [][]any{{int32(2), int32(2), make([]byte, 4)}}, false},
+		{"too wide", [][]any{{DefaultIconLimits.MaxWidth + 1, int32(1), []byte{}}}, false},
+		{"invalid format", [][]any{{int32(1), int32(1)}}, false},
+	}
+
+	for _, tt := range tests {
+		if _, ok := pixmapsHash(tt.pixmaps); ok != tt.ok {
+			t.Errorf("%s: pixmapsHash ok = %t, want %t", tt.name, ok, tt.ok)
+		}
+	}
+}
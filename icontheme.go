@@ -0,0 +1,604 @@
+package systray
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupportedIconFormat is returned when icon file cannot be decoded, e.g.
+// because it is an SVG or XPM image.
+var ErrUnsupportedIconFormat = errors.New("unsupported icon format")
+
+// iconExtensions lists extensions of icon files in order of preference.
+var iconExtensions = []string{".png", ".svg", ".xpm"}
+
+type iconDirType string
+
+// Types of icon theme directories.
+const (
+	iconDirFixed     iconDirType = "Fixed"
+	iconDirScalable  iconDirType = "Scalable"
+	iconDirThreshold iconDirType = "Threshold"
+)
+
+// iconDir describes a directory of icon theme, as specified in index.theme.
+type iconDir struct {
+	path      string
+	size      int
+	scale     int
+	minSize   int
+	maxSize   int
+	threshold int
+	dirType   iconDirType
+}
+
+// matchesSize implements DirectoryMatchesSize of the Icon Theme Specification.
+func (d *iconDir) matchesSize(size, scale int) bool {
+	if d.scale != scale {
+		return false
+	}
+
+	switch d.dirType {
+	case iconDirFixed:
+		return d.size == size
+	case iconDirScalable:
+		return d.minSize <= size && size <= d.maxSize
+	default:
+		return d.size-d.threshold <= size && size <= d.size+d.threshold
+	}
+}
+
+// sizeDistance implements DirectorySizeDistance of the Icon Theme
+// Specification.
+func (d *iconDir) sizeDistance(size, scale int) int {
+	switch d.dirType {
+	case iconDirFixed:
+		return abs(d.size*d.scale - size*scale)
+	case iconDirScalable:
+		if size*scale < d.minSize*d.scale {
+			return d.minSize*d.scale - size*scale
+		}
+		if size*scale > d.maxSize*d.scale {
+			return size*scale - d.maxSize*d.scale
+		}
+		return 0
+	default:
+		// The specification uses MinSize and MaxSize here, which is a known
+		// mistake: they are not defined for Threshold directories.
+		if size*scale < (d.size-d.threshold)*d.scale {
+			return (d.size-d.threshold)*d.scale - size*scale
+		}
+		if size*scale > (d.size+d.threshold)*d.scale {
+			return size*scale - (d.size+d.threshold)*d.scale
+		}
+		return 0
+	}
+}
+
+// iconThemeIndex is the parsed index.theme file.
+type iconThemeIndex struct {
+	name     string
+	inherits []string
+	dirs     []*iconDir
+}
+
+// parseIconThemeIndex parses index.theme file of the theme with the given name.
+func parseIconThemeIndex(name, path string) (*iconThemeIndex, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	groups := make(map[string]map[string]string)
+	group := ""
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			group = line[1 : len(line)-1]
+			if groups[group] == nil {
+				groups[group] = make(map[string]string)
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || group == "" {
+			continue
+		}
+
+		groups[group][strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	theme, ok := groups["Icon Theme"]
+	if !ok {
+		return nil, fmt.Errorf("%s: missing [Icon Theme] group", path)
+	}
+
+	index := &iconThemeIndex{
+		name:     name,
+		inherits: splitList(theme["Inherits"]),
+	}
+
+	dirs := append(splitList(theme["Directories"]), splitList(theme["ScaledDirectories"])...)
+
+	for _, dir := range dirs {
+		props, ok := groups[dir]
+		if !ok {
+			continue
+		}
+
+		size, err := strconv.Atoi(props["Size"])
+		if err != nil {
+			continue
+		}
+
+		d := &iconDir{
+			path:      dir,
+			size:      size,
+			scale:     atoiDefault(props["Scale"], 1),
+			minSize:   atoiDefault(props["MinSize"], size),
+			maxSize:   atoiDefault(props["MaxSize"], size),
+			threshold: atoiDefault(props["Threshold"], 2),
+			dirType:   iconDirType(props["Type"]),
+		}
+
+		if d.dirType != iconDirFixed && d.dirType != iconDirScalable {
+			d.dirType = iconDirThreshold
+		}
+
+		index.dirs = append(index.dirs, d)
+	}
+
+	return index, nil
+}
+
+// IconTheme resolves icon names into files, following the
+// [Icon Theme Specification].
+//
+// Icons are searched in the selected theme and the themes it inherits, then
+// in the hicolor theme, and finally directly in base directories, such as
+// /usr/share/pixmaps. Parsed index.theme files and directory listings are
+// cached. Use [IconTheme.Reload] to pick up changes on disk.
+//
+// [Icon Theme Specification]: https://specifications.freedesktop.org/icon-theme-spec/latest/
+type IconTheme struct {
+	name     string
+	baseDirs []string
+
+	mu sync.Mutex
+
+	// Parsed index.theme files by their paths, nil if file is missing.
+	indexes map[string]*iconThemeIndex
+
+	// Names of files by directory paths.
+	listings map[string]map[string]bool
+
+	// Incremented on every reload to invalidate icons cached in [IconCache].
+	revision uint64
+}
+
+// NewIconTheme returns a new [IconTheme] with the given name, e.g. "breeze".
+// Empty name defaults to "hicolor".
+//
+// Base directories are searched for themes in order of preference. If no base
+// directories are specified, [DefaultIconBaseDirs] are used.
+func NewIconTheme(name string, baseDirs ...string) *IconTheme {
+	if name == "" {
+		name = "hicolor"
+	}
+
+	if len(baseDirs) == 0 {
+		baseDirs = DefaultIconBaseDirs()
+	}
+
+	return &IconTheme{
+		name:     name,
+		baseDirs: baseDirs,
+		indexes:  make(map[string]*iconThemeIndex),
+		listings: make(map[string]map[string]bool),
+	}
+}
+
+var (
+	defaultIconThemeOnce sync.Once
+	defaultIconTheme     *IconTheme
+)
+
+// DefaultIconTheme returns [IconTheme] configured for the current user. Name
+// of the theme is read from GTK 3 settings or KDE configuration. If neither is
+// available, hicolor theme is used.
+func DefaultIconTheme() *IconTheme {
+	defaultIconThemeOnce.Do(func() {
+		defaultIconTheme = NewIconTheme(detectIconThemeName())
+	})
+
+	return defaultIconTheme
+}
+
+// DefaultIconBaseDirs returns base directories for icon themes, as defined by
+// the Icon Theme Specification: $HOME/.icons, $XDG_DATA_DIRS/icons, and
+// /usr/share/pixmaps.
+func DefaultIconBaseDirs() []string {
+	dirs := make([]string, 0, 5)
+
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".icons"))
+	}
+
+	for _, dir := range xdgDataDirs() {
+		dirs = append(dirs, filepath.Join(dir, "icons"))
+	}
+
+	return append(dirs, "/usr/share/pixmaps")
+}
+
+// Name returns name of the theme.
+func (t *IconTheme) Name() string {
+	return t.name
+}
+
+// Reload discards cached index.theme files and directory listings, as well as
+// icons of the theme stored in [IconCache].
+func (t *IconTheme) Reload() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	clear(t.indexes)
+	clear(t.listings)
+	t.revision++
+}
+
+// currentRevision returns number of times the theme was reloaded.
+func (t *IconTheme) currentRevision() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.revision
+}
+
+// Lookup returns path to the file of the icon with the given name, that best
+// matches size and scale.
+//
+// Extra directories are searched before base directories of the theme. They
+// are typically obtained from IconThemePath property of [Item] or [Menu].
+//
+// If name is an absolute path to an existing file, it is returned as is. If
+// icon is not found, false is returned.
+func (t *IconTheme) Lookup(name string, size, scale int, extraDirs ...string) (string, bool) {
+	return t.lookup(t.query(name, size, scale, iconExtensions, extraDirs))
+}
+
+// LoadIcon resolves icon as [IconTheme.Lookup] does and decodes it into
+// [Icon].
+//
+// Only PNG icons can be decoded, so that other formats are not considered by
+// the lookup, even if they match size better. If name is an absolute path to
+// a file of another format, an error wrapping [ErrUnsupportedIconFormat] is
+// returned.
+func (t *IconTheme) LoadIcon(name string, size, scale int, extraDirs ...string) (*Icon, error) {
+	path, ok := t.lookup(t.query(name, size, scale, []string{".png"}, extraDirs))
+	if !ok {
+		return nil, fmt.Errorf("load icon: icon %s not found in theme %s", name, t.name)
+	}
+
+	return loadIconFile(path)
+}
+
+// loadIconFile decodes icon file at path.
+func loadIconFile(path string) (*Icon, error) {
+	if !strings.EqualFold(filepath.Ext(path), ".png") {
+		return nil, fmt.Errorf("load icon %s: %w", path, ErrUnsupportedIconFormat)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("load icon: %w", err)
+	}
+	defer file.Close()
+
+	icon, err := DecodePNG(file)
+	if err != nil {
+		return nil, fmt.Errorf("load icon %s: %w", path, err)
+	}
+
+	return icon, nil
+}
+
+// iconQuery describes lookup of a file in the theme.
+type iconQuery struct {
+	name  string
+	size  int
+	scale int
+
+	// Extensions of the file in order of preference. Extension "/" matches
+	// directories.
+	extensions []string
+
+	// Base directories, including extra ones.
+	baseDirs []string
+}
+
+// query returns [iconQuery] for the theme.
+func (t *IconTheme) query(name string, size, scale int, extensions, extraDirs []string) *iconQuery {
+	return &iconQuery{
+		name:       name,
+		size:       size,
+		scale:      max(scale, 1),
+		extensions: extensions,
+		baseDirs:   append(extraDirs[:len(extraDirs):len(extraDirs)], t.baseDirs...),
+	}
+}
+
+// lookup implements FindIcon of the Icon Theme Specification.
+func (t *IconTheme) lookup(q *iconQuery) (string, bool) {
+	if q.name == "" {
+		return "", false
+	}
+
+	if filepath.IsAbs(q.name) {
+		_, err := os.Stat(q.name)
+		return q.name, err == nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	visited := make(map[string]bool)
+
+	if path, ok := t.findIconHelper(q, t.name, visited); ok {
+		return path, true
+	}
+
+	if path, ok := t.findIconHelper(q, "hicolor", visited); ok {
+		return path, true
+	}
+
+	return t.lookupFallbackIcon(q)
+}
+
+// findIconHelper implements FindIconHelper of the Icon Theme Specification.
+//
+// The caller must hold t.mu.
+func (t *IconTheme) findIconHelper(q *iconQuery, theme string, visited map[string]bool) (string, bool) {
+	if visited[theme] {
+		return "", false
+	}
+
+	visited[theme] = true
+
+	index := t.index(theme, q.baseDirs)
+	if index == nil {
+		return "", false
+	}
+
+	if path, ok := t.lookupIcon(q, index); ok {
+		return path, true
+	}
+
+	for _, parent := range index.inherits {
+		if path, ok := t.findIconHelper(q, parent, visited); ok {
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
+// lookupIcon implements LookupIcon of the Icon Theme Specification.
+//
+// The caller must hold t.mu.
+func (t *IconTheme) lookupIcon(q *iconQuery, index *iconThemeIndex) (string, bool) {
+	for _, dir := range index.dirs {
+		if !dir.matchesSize(q.size, q.scale) {
+			continue
+		}
+
+		if path, ok := t.findInDirs(q, index.name, dir.path); ok {
+			return path, true
+		}
+	}
+
+	closest := ""
+	minDistance := math.MaxInt
+
+	for _, dir := range index.dirs {
+		distance := dir.sizeDistance(q.size, q.scale)
+		if distance >= minDistance {
+			continue
+		}
+
+		if path, ok := t.findInDirs(q, index.name, dir.path); ok {
+			closest = path
+			minDistance = distance
+		}
+	}
+
+	return closest, closest != ""
+}
+
+// lookupFallbackIcon implements LookupFallbackIcon of the Icon Theme
+// Specification.
+//
+// The caller must hold t.mu.
+func (t *IconTheme) lookupFallbackIcon(q *iconQuery) (string, bool) {
+	return t.findInDirs(q)
+}
+
+// findInDirs returns path to the file in the first base directory that
+// contains it. Parameter subdirs is joined with each base directory.
+//
+// The caller must hold t.mu.
+func (t *IconTheme) findInDirs(q *iconQuery, subdirs ...string) (string, bool) {
+	for _, base := range q.baseDirs {
+		dir := filepath.Join(append([]string{base}, subdirs...)...)
+		files := t.listing(dir)
+
+		for _, ext := range q.extensions {
+			if files[q.name+ext] {
+				return filepath.Join(dir, q.name+ext), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// listing returns cached names of files in dir. Names of subdirectories have
+// trailing "/".
+//
+// The caller must hold t.mu.
+func (t *IconTheme) listing(dir string) map[string]bool {
+	if files, ok := t.listings[dir]; ok {
+		return files
+	}
+
+	entries, _ := os.ReadDir(dir)
+	files := make(map[string]bool, len(entries))
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			files[entry.Name()+"/"] = true
+		} else {
+			files[entry.Name()] = true
+		}
+	}
+
+	t.listings[dir] = files
+
+	return files
+}
+
+// index returns parsed index.theme of the theme from the first base directory
+// that contains it, or nil if the theme cannot be found.
+//
+// The caller must hold t.mu.
+func (t *IconTheme) index(theme string, baseDirs []string) *iconThemeIndex {
+	for _, base := range baseDirs {
+		path := filepath.Join(base, theme, "index.theme")
+
+		index, ok := t.indexes[path]
+		if !ok {
+			index, _ = parseIconThemeIndex(theme, path)
+			t.indexes[path] = index
+		}
+
+		if index != nil {
+			return index
+		}
+	}
+
+	return nil
+}
+
+// detectIconThemeName returns name of the icon theme selected by the user in
+// GTK 3 settings or KDE configuration, or empty string if it is not
+// configured.
+func detectIconThemeName() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(configHome) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+
+	candidates := []struct {
+		path  string
+		group string
+		key   string
+	}{
+		{filepath.Join(configHome, "gtk-3.0", "settings.ini"), "Settings", "gtk-icon-theme-name"},
+		{filepath.Join(configHome, "kdeglobals"), "Icons", "Theme"},
+	}
+
+	for _, c := range candidates {
+		if value := readIniValue(c.path, c.group, c.key); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// readIniValue returns value of the key in the group of INI-like file, or
+// empty string if it is not present.
+func readIniValue(path, group, key string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	inGroup := false
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if strings.HasPrefix(line, "[") {
+			inGroup = line == "["+group+"]"
+			continue
+		}
+
+		k, v, ok := strings.Cut(line, "=")
+		if inGroup && ok && strings.TrimSpace(k) == key {
+			return strings.TrimSpace(v)
+		}
+	}
+
+	return ""
+}
+
+// splitList splits comma-separated list, omitting empty elements.
+func splitList(list string) []string {
+	result := make([]string, 0)
+
+	for item := range strings.SplitSeq(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+// atoiDefault converts s to integer, or returns def if s is not a valid
+// integer.
+func atoiDefault(s string, def int) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+
+	return n
+}
+
+// abs returns absolute value of n.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
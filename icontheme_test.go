@@ -0,0 +1,274 @@
+package systray
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestFiles creates files with the given contents relative to dir.
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// encodeTestPNG returns PNG image of the given size.
+func encodeTestPNG(t *testing.T, width, height int) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+// newTestIconTheme creates icon theme tree in a temporary directory and
+// returns theme "test" along with base directories of the tree.
+func newTestIconTheme(t *testing.T) (theme *IconTheme, icons, pixmaps string) {
+	t.Helper()
+
+	root := t.TempDir()
+	icons = filepath.Join(root, "icons")
+	pixmaps = filepath.Join(root, "pixmaps")
+
+	writeTestFiles(t, icons, map[string]string{
+		"test/index.theme": `[Icon Theme]
+Name=Test
+Inherits=parent
+Directories=16x16/apps,32x32/apps,scalable/apps
+ScaledDirectories=16x16@2/apps
+
+[16x16/apps]
+Size=16
+Type=Fixed
+
+[32x32/apps]
+Size=32
+Type=Threshold
+Threshold=4
+
+[scalable/apps]
+Size=64
+MinSize=64
+MaxSize=256
+Type=Scalable
+
+[16x16@2/apps]
+Size=16
+Scale=2
+Type=Fixed
+`,
+		"test/16x16/apps/fixed.png":       "",
+		"test/32x32/apps/fixed.png":       "",
+		"test/32x32/apps/threshold.png":   "",
+		"test/16x16/apps/scalable.png":    "",
+		"test/scalable/apps/scalable.svg": "",
+		"test/16x16/apps/scaled.png":      "",
+		"test/16x16@2/apps/scaled.png":    "",
+
+		// Inheritance cycle must not result in infinite recursion.
+		"parent/index.theme": `[Icon Theme]
+Name=Parent
+Inherits=test
+Directories=24/apps
+
+[24/apps]
+Size=24
+`,
+		"parent/24/apps/inherited.png": "",
+
+		"hicolor/index.theme": `[Icon Theme]
+Name=Hicolor
+Directories=48x48/apps
+
+[48x48/apps]
+Size=48
+Type=Fixed
+`,
+		"hicolor/48x48/apps/hicolor.png": "",
+		"hicolor/48x48/apps/fixed.png":   "",
+	})
+
+	writeTestFiles(t, pixmaps, map[string]string{
+		"pixmap.xpm": "",
+	})
+
+	return NewIconTheme("test", icons, pixmaps), icons, pixmaps
+}
+
+func TestIconThemeLookup(t *testing.T) {
+	theme, icons, pixmaps := newTestIconTheme(t)
+
+	tests := []struct {
+		name  string
+		size  int
+		scale int
+		want  string
+	}{
+		// Fixed directories match exact size only.
+		{"fixed", 16, 1, "test/16x16/apps/fixed.png"},
+		{"fixed", 32, 1, "test/32x32/apps/fixed.png"},
+
+		// Threshold directory matches 28-36 and is the closest to 24.
+		{"fixed", 24, 1, "test/32x32/apps/fixed.png"},
+		{"threshold", 30, 1, "test/32x32/apps/threshold.png"},
+		{"threshold", 16, 1, "test/32x32/apps/threshold.png"},
+
+		// Scalable directory matches 64-256.
+		{"scalable", 128, 1, "test/scalable/apps/scalable.svg"},
+		{"scalable", 16, 1, "test/16x16/apps/scalable.png"},
+		{"scalable", 512, 1, "test/scalable/apps/scalable.svg"},
+
+		// Scaled directories match the scale.
+		{"scaled", 16, 1, "test/16x16/apps/scaled.png"},
+		{"scaled", 16, 2, "test/16x16@2/apps/scaled.png"},
+		{"fixed", 16, 2, "test/32x32/apps/fixed.png"},
+
+		// Icons are searched in inherited themes, then in hicolor.
+		{"inherited", 16, 1, "parent/24/apps/inherited.png"},
+		{"hicolor", 16, 1, "hicolor/48x48/apps/hicolor.png"},
+	}
+
+	for _, tt := range tests {
+		path, ok := theme.Lookup(tt.name, tt.size, tt.scale)
+		if !ok {
+			t.Errorf("Lookup(%q, %d, %d): not found", tt.name, tt.size, tt.scale)
+			continue
+		}
+
+		if want := filepath.Join(icons, tt.want); path != want {
+			t.Errorf("Lookup(%q, %d, %d) = %s, want %s", tt.name, tt.size, tt.scale, path, want)
+		}
+	}
+
+	if path, ok := theme.Lookup("missing", 16, 1); ok {
+		t.Errorf("Lookup(%q) = %s, want not found", "missing", path)
+	}
+
+	// Icons missing from themes are searched directly in base directories.
+	want := filepath.Join(pixmaps, "pixmap.xpm")
+	if path, ok := theme.Lookup("pixmap", 16, 1); !ok || path != want {
+		t.Errorf("Lookup(%q) = %s, %t, want %s", "pixmap", path, ok, want)
+	}
+}
+
+func TestIconThemeLookupExtraDirs(t *testing.T) {
+	theme, _, _ := newTestIconTheme(t)
+
+	extra := t.TempDir()
+	writeTestFiles(t, extra, map[string]string{
+		"extra.png": "",
+		"test/index.theme": `[Icon Theme]
+Name=Test
+Directories=16x16/apps
+
+[16x16/apps]
+Size=16
+Type=Fixed
+`,
+		"test/16x16/apps/fixed.png": "",
+	})
+
+	if _, ok := theme.Lookup("extra", 16, 1); ok {
+		t.Error("icon from extra directory found without the directory")
+	}
+
+	if path, ok := theme.Lookup("extra", 16, 1, extra); !ok || path != filepath.Join(extra, "extra.png") {
+		t.Errorf("Lookup(%q) = %s, %t, want file in extra directory", "extra", path, ok)
+	}
+
+	// Extra directories take precedence over base directories.
+	want := filepath.Join(extra, "test/16x16/apps/fixed.png")
+	if path, ok := theme.Lookup("fixed", 16, 1, extra); !ok || path != want {
+		t.Errorf("Lookup(%q) = %s, %t, want %s", "fixed", path, ok, want)
+	}
+}
+
+func TestIconThemeReload(t *testing.T) {
+	theme, icons, _ := newTestIconTheme(t)
+
+	if _, ok := theme.Lookup("added", 16, 1); ok {
+		t.Fatal("icon found before it was added")
+	}
+
+	writeTestFiles(t, icons, map[string]string{
+		"test/16x16/apps/added.png": "",
+	})
+
+	if _, ok := theme.Lookup("added", 16, 1); ok {
+		t.Error("directory listing was not cached")
+	}
+
+	theme.Reload()
+
+	if _, ok := theme.Lookup("added", 16, 1); !ok {
+		t.Error("icon not found after reload")
+	}
+}
+
+func TestIconThemeLoadIconPrefersPNG(t *testing.T) {
+	icons := t.TempDir()
+
+	writeTestFiles(t, icons, map[string]string{
+		"hicolor/index.theme": `[Icon Theme]
+Name=Hicolor
+Directories=48x48/apps,scalable/apps
+
+[48x48/apps]
+Size=48
+Type=Fixed
+
+[scalable/apps]
+Size=128
+MinSize=1
+MaxSize=512
+Type=Scalable
+`,
+		"hicolor/48x48/apps/app.png":       encodeTestPNG(t, 48, 48),
+		"hicolor/scalable/apps/app.svg":    "<svg/>",
+		"hicolor/scalable/apps/vector.svg": "<svg/>",
+	})
+
+	theme := NewIconTheme("hicolor", icons)
+
+	// Scalable directory matches the size, but SVG cannot be decoded.
+	if path, _ := theme.Lookup("app", 22, 1); filepath.Ext(path) != ".svg" {
+		t.Fatalf("Lookup = %s, want SVG icon", path)
+	}
+
+	icon, err := theme.LoadIcon("app", 22, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if icon.Width != 48 || icon.Height != 48 {
+		t.Errorf("size = %dx%d, want 48x48", icon.Width, icon.Height)
+	}
+
+	if _, err := theme.LoadIcon("vector", 22, 1); err == nil {
+		t.Error("icon without PNG file was loaded")
+	}
+
+	svg := filepath.Join(icons, "hicolor/scalable/apps/vector.svg")
+	if _, err := theme.LoadIcon(svg, 22, 1); !errors.Is(err, ErrUnsupportedIconFormat) {
+		t.Errorf("LoadIcon(%s) error = %v, want %v", svg, err, ErrUnsupportedIconFormat)
+	}
+}
@@ -0,0 +1,344 @@
+package systray
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultItemStateTTL is the default time [Host] keeps state of items that are
+// no longer registered, such as position and hidden state.
+const DefaultItemStateTTL = 24 * time.Hour
+
+// maxItemStates is the maximum number of states of items that are no longer
+// registered. States of items unregistered earliest are discarded first.
+const maxItemStates = 256
+
+// Key returns identifier of the item that remains stable across restarts of
+// the application, unlike [Item.BusName].
+//
+// The key is derived from ID and category of the item, and desktop file ID of
+// the application (or its executable, if desktop entry cannot be found). The
+// key is computed once and cached for the lifetime of the item.
+func (item *Item) Key() string {
+	item.mu.Lock()
+	key := item.key
+	item.mu.Unlock()
+
+	if key != "" {
+		return key
+	}
+
+	app := ""
+
+	if entry, err := item.DesktopEntry(); err == nil {
+		app = entry.ID
+	} else if process, err := item.Process(); err == nil {
+		app = process.Executable
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		item.ID,
+		string(item.Category),
+		app,
+	}, "\x00")))
+
+	key = hex.EncodeToString(sum[:16])
+
+	item.mu.Lock()
+	item.key = key
+	item.mu.Unlock()
+
+	return key
+}
+
+// itemState is state associated with the item key by [Host]. It outlives the
+// item, so that it can be restored when application restarts.
+type itemState struct {
+	hidden      bool
+	preferences map[string]any
+
+	// Time when an item with the key was last registered or unregistered.
+	lastSeen time.Time
+}
+
+// OnReregister sets callback that runs whenever host registers an item that
+// has the same [Item.Key] as an item registered previously, e.g. because the
+// application was restarted. The item takes position of the previous one and
+// keeps its hidden state and preferences.
+//
+// If OnReregister callback is not set, callback set by [Host.OnRegister] runs
+// instead.
+func (h *Host) OnReregister(callback func(*Item)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.onReregister = callback
+}
+
+// SetItemStateTTL sets how long state of an item, such as its position and
+// hidden state, is kept after the item is unregistered. If the item is not
+// re-registered within this time, it is considered new when it registers
+// again.
+//
+// Zero or negative ttl keeps state indefinitely, up to a fixed number of
+// items. The default is [DefaultItemStateTTL].
+func (h *Host) SetItemStateTTL(ttl time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stateTTL = ttl
+	h.pruneStates(time.Now())
+}
+
+// Move changes position of the item among [Host.Items]. Position is kept when
+// the item is re-registered.
+func (h *Host) Move(item *Item, position int) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key, exists := h.keys[item.uniqueName]
+	if !exists {
+		return fmt.Errorf("move: item %s is not registered", item.uniqueName)
+	}
+
+	idx := slices.Index(h.order, key)
+	h.order = slices.Delete(h.order, idx, idx+1)
+
+	// Position is relative to registered items, whereas order also contains
+	// keys of items that are gone.
+	target := len(h.order)
+	registered := 0
+
+	for idx, k := range h.order {
+		if !h.isKeyRegistered(k) {
+			continue
+		}
+
+		if registered == position {
+			target = idx
+			break
+		}
+
+		registered++
+	}
+
+	h.order = slices.Insert(h.order, target, key)
+
+	return nil
+}
+
+// SetHidden sets whether the item should be hidden by the visualization. The
+// hidden state is kept when the item is re-registered.
+func (h *Host) SetHidden(item *Item, hidden bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if state := h.state(item); state != nil {
+		state.hidden = hidden
+	}
+}
+
+// IsHidden reports whether the item was hidden with [Host.SetHidden].
+func (h *Host) IsHidden(item *Item) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	state := h.state(item)
+	return state != nil && state.hidden
+}
+
+// SetPreference stores arbitrary preference of the visualization for the
+// item. Preferences are kept when the item is re-registered.
+func (h *Host) SetPreference(item *Item, name string, value any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if state := h.state(item); state != nil {
+		state.preferences[name] = value
+	}
+}
+
+// Preference returns preference stored with [Host.SetPreference].
+func (h *Host) Preference(item *Item, name string) (any, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	state := h.state(item)
+	if state == nil {
+		return nil, false
+	}
+
+	value, exists := state.preferences[name]
+	return value, exists
+}
+
+// state returns state associated with the item, or nil if item is not
+// registered.
+//
+// The caller must hold h.mu.
+func (h *Host) state(item *Item) *itemState {
+	key, exists := h.keys[item.uniqueName]
+	if !exists {
+		return nil
+	}
+
+	return h.states[key]
+}
+
+// assignKey associates the item with its key and reports whether an item with
+// the same key was registered before.
+//
+// Multiple items with the same [Item.Key] may be registered at a time, e.g.
+// when application shows several icons. Such items are distinguished by
+// numeric suffix in order of registration.
+//
+// Restarted application may register its item before the watcher announces
+// that the previous instance is gone. Registered item whose owner has left the
+// bus is therefore removed, and the new item takes its key.
+//
+// The caller must hold h.mu.
+func (h *Host) assignKey(item *Item) bool {
+	now := time.Now()
+	h.pruneStates(now)
+
+	base := item.Key()
+	key := base
+
+	for n := 2; ; n++ {
+		previous := h.itemByKey(key)
+		if previous == nil {
+			break
+		}
+
+		if !h.hasOwner(previous.uniqueName) {
+			h.removeItem(previous)
+			break
+		}
+
+		key = fmt.Sprintf("%s#%d", base, n)
+	}
+
+	h.keys[item.uniqueName] = key
+
+	if state, known := h.states[key]; known {
+		state.lastSeen = now
+		return true
+	}
+
+	h.states[key] = &itemState{
+		preferences: make(map[string]any),
+		lastSeen:    now,
+	}
+	h.order = append(h.order, key)
+
+	return false
+}
+
+// releaseKey records that the item with the key is no longer registered.
+//
+// The caller must hold h.mu.
+func (h *Host) releaseKey(key string) {
+	now := time.Now()
+
+	if state, exists := h.states[key]; exists {
+		state.lastSeen = now
+	}
+
+	h.pruneStates(now)
+}
+
+// pruneStates discards states of unregistered items that were not seen for
+// longer than state TTL, as well as the oldest states in excess of
+// [maxItemStates].
+//
+// The caller must hold h.mu.
+func (h *Host) pruneStates(now time.Time) {
+	registered := make(map[string]bool, len(h.keys))
+	for _, key := range h.keys {
+		registered[key] = true
+	}
+
+	var stale []string
+
+	for _, key := range h.order {
+		if registered[key] {
+			continue
+		}
+
+		if h.stateTTL > 0 && now.Sub(h.states[key].lastSeen) > h.stateTTL {
+			delete(h.states, key)
+			continue
+		}
+
+		stale = append(stale, key)
+	}
+
+	if len(stale) > maxItemStates {
+		slices.SortFunc(stale, func(a, b string) int {
+			return h.states[a].lastSeen.Compare(h.states[b].lastSeen)
+		})
+
+		for _, key := range stale[:len(stale)-maxItemStates] {
+			delete(h.states, key)
+		}
+	}
+
+	h.order = slices.DeleteFunc(h.order, func(key string) bool {
+		_, exists := h.states[key]
+		return !exists
+	})
+}
+
+// itemByKey returns registered item with the key, or nil if there is no such
+// item.
+//
+// The caller must hold h.mu.
+func (h *Host) itemByKey(key string) *Item {
+	for uniqueName, k := range h.keys {
+		if k == key {
+			return h.items[uniqueName]
+		}
+	}
+
+	return nil
+}
+
+// hasOwner reports whether unique name is still owned on the bus. Errors are
+// treated as if the name is owned, so that registered items are not removed
+// by mistake.
+//
+// The caller must hold h.mu.
+func (h *Host) hasOwner(uniqueName string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultCallTimeout)
+	defer cancel()
+
+	var owned bool
+
+	err := h.conn.BusObject().CallWithContext(
+		ctx,
+		"org.freedesktop.DBus.NameHasOwner",
+		0,
+		uniqueName,
+	).Store(&owned)
+
+	return err != nil || owned
+}
+
+// isKeyRegistered reports whether an item with the key is currently
+// registered.
+//
+// The caller must hold h.mu.
+func (h *Host) isKeyRegistered(key string) bool {
+	for _, k := range h.keys {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,119 @@
+package systray
+
+import (
+	"testing"
+	"time"
+)
+
+// addTestItem creates [Item] for the fake item and registers it in the host.
+func addTestItem(t *testing.T, h *Host, fake *fakeItem) *Item {
+	t.Helper()
+
+	item, err := NewItem(h.conn, fake.name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.mu.Lock()
+	h.addItem(item)
+	h.mu.Unlock()
+
+	return item
+}
+
+// disconnectTestItem closes connection of the fake item and waits until the
+// bus releases its unique name.
+func disconnectTestItem(t *testing.T, h *Host, fake *fakeItem) {
+	t.Helper()
+
+	name := fake.name()
+	fake.conn.Close()
+
+	for range 100 {
+		if !h.hasOwner(name) {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("name %s is still owned", name)
+}
+
+func TestHostReregisterBeforeUnregistered(t *testing.T) {
+	address := startTestBus(t)
+	h := NewHost(connectTestBus(t, address), 1)
+
+	var registered, reregistered, unregistered []*Item
+	h.OnRegister(func(item *Item) { registered = append(registered, item) })
+	h.OnReregister(func(item *Item) { reregistered = append(reregistered, item) })
+	h.OnUnregister(func(item *Item) { unregistered = append(unregistered, item) })
+
+	oldFake := newFakeItem(t, address)
+	old := addTestItem(t, h, oldFake)
+	other := addTestItem(t, h, newFakeItem(t, address))
+
+	// Items of the same application with the same ID share the base key.
+	if want := h.keys[old.uniqueName] + "#2"; h.keys[other.uniqueName] != want {
+		t.Fatalf("key = %s, want %s", h.keys[other.uniqueName], want)
+	}
+
+	h.SetHidden(old, true)
+
+	// Application restarts, and its new item is registered before the
+	// watcher announces that the old one is gone.
+	disconnectTestItem(t, h, oldFake)
+	restarted := addTestItem(t, h, newFakeItem(t, address))
+
+	if len(registered) != 2 {
+		t.Errorf("OnRegister called %d times, want 2", len(registered))
+	}
+
+	if len(reregistered) != 1 || reregistered[0] != restarted {
+		t.Errorf("OnReregister called with %v, want restarted item", reregistered)
+	}
+
+	if len(unregistered) != 1 || unregistered[0] != old {
+		t.Errorf("OnUnregister called with %v, want old item", unregistered)
+	}
+
+	if !h.IsHidden(restarted) {
+		t.Error("hidden state of the old item was not restored")
+	}
+
+	items := h.Items()
+	if len(items) != 2 || items[0] != restarted || items[1] != other {
+		t.Errorf("Items() = %v, want restarted item in place of the old one", items)
+	}
+}
+
+func TestHostPrunesItemStates(t *testing.T) {
+	address := startTestBus(t)
+	h := NewHost(connectTestBus(t, address), 1)
+
+	item := addTestItem(t, h, newFakeItem(t, address))
+
+	h.mu.Lock()
+	h.removeItem(item)
+	h.mu.Unlock()
+
+	if len(h.states) != 1 || len(h.order) != 1 {
+		t.Fatalf("state of unregistered item was discarded before TTL")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	h.SetItemStateTTL(time.Millisecond)
+
+	if len(h.states) != 0 || len(h.order) != 0 {
+		t.Errorf("state of unregistered item was kept after TTL: %d states, %d keys", len(h.states), len(h.order))
+	}
+
+	reregistered := false
+	h.OnReregister(func(*Item) { reregistered = true })
+
+	addTestItem(t, h, newFakeItem(t, address))
+
+	if reregistered {
+		t.Error("item registered after TTL was reported as re-registered")
+	}
+}
@@ -0,0 +1,126 @@
+package systray
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+)
+
+// ColorModel implements [image.Image].
+func (icon *Icon) ColorModel() color.Model {
+	return color.NRGBAModel
+}
+
+// Bounds implements [image.Image].
+func (icon *Icon) Bounds() image.Rectangle {
+	return image.Rect(0, 0, int(icon.Width), int(icon.Height))
+}
+
+// At implements [image.Image]. Pixels outside of bounds, or missing from Bytes,
+// are transparent.
+func (icon *Icon) At(x, y int) color.Color {
+	return icon.NRGBAAt(x, y)
+}
+
+// NRGBAAt returns color of the pixel at (x, y).
+func (icon *Icon) NRGBAAt(x, y int) color.NRGBA {
+	if !(image.Point{x, y}.In(icon.Bounds())) {
+		return color.NRGBA{}
+	}
+
+	offset := (y*int(icon.Width) + x) * 4
+	if offset+4 > len(icon.Bytes) {
+		return color.NRGBA{}
+	}
+
+	p := icon.Bytes[offset : offset+4 : offset+4]
+
+	return color.NRGBA{
+		R: p[1],
+		G: p[2],
+		B: p[3],
+		A: p[0],
+	}
+}
+
+// Image returns icon converted to [image.NRGBA]. Unlike the icon itself, the
+// returned image can be drawn efficiently by the standard library.
+func (icon *Icon) Image() *image.NRGBA {
+	img := image.NewNRGBA(icon.Bounds())
+
+	n := min(len(img.Pix), len(icon.Bytes)) / 4 * 4
+
+	for offset := 0; offset < n; offset += 4 {
+		src := icon.Bytes[offset : offset+4 : offset+4]
+		dst := img.Pix[offset : offset+4 : offset+4]
+
+		dst[0] = src[1]
+		dst[1] = src[2]
+		dst[2] = src[3]
+		dst[3] = src[0]
+	}
+
+	return img
+}
+
+// EncodePNG writes icon to w in PNG format.
+func (icon *Icon) EncodePNG(w io.Writer) error {
+	if err := png.Encode(w, icon.Image()); err != nil {
+		return fmt.Errorf("encode png: %w", err)
+	}
+
+	return nil
+}
+
+// DecodePNG reads PNG image from r and returns it as [Icon].
+func DecodePNG(r io.Reader) (*Icon, error) {
+	img, err := png.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("decode png: %w", err)
+	}
+
+	return NewIconFromImage(img), nil
+}
+
+// NewIconFromImage returns a new [Icon] from arbitrary image. The image is
+// converted to non-premultiplied ARGB32 in network byte order, which is
+// the format of StatusNotifierItem pixmaps.
+func NewIconFromImage(img image.Image) *Icon {
+	bounds := img.Bounds()
+
+	nrgba, ok := img.(*image.NRGBA)
+	if !ok {
+		nrgba = image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+		bounds = nrgba.Bounds()
+	}
+
+	rowSize := bounds.Dx() * 4
+	pixels := make([]byte, rowSize*bounds.Dy())
+
+	// Rows of sub-images are not contiguous, and Pix of sub-images extends
+	// beyond their bounds, so that rows are copied one by one.
+	for y := range bounds.Dy() {
+		start := nrgba.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+		row := nrgba.Pix[start : start+rowSize : start+rowSize]
+
+		for offset := 0; offset+4 <= rowSize; offset += 4 {
+			src := row[offset : offset+4 : offset+4]
+			dst := pixels[y*rowSize+offset : y*rowSize+offset+4 : y*rowSize+offset+4]
+
+			dst[0] = src[3]
+			dst[1] = src[0]
+			dst[2] = src[1]
+			dst[3] = src[2]
+		}
+	}
+
+	return &Icon{
+		Width:  int32(bounds.Dx()),
+		Height: int32(bounds.Dy()),
+		Bytes:  pixels,
+	}
+}
@@ -0,0 +1,47 @@
+package systray
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestNewIconFromImageSubImage(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := range 4 {
+		for x := range 4 {
+			src.SetNRGBA(x, y, color.NRGBA{uint8(x), uint8(y), 0x80, 0xff})
+		}
+	}
+
+	tests := []struct {
+		name string
+		rect image.Rectangle
+	}{
+		{"top rows", image.Rect(0, 0, 4, 2)},
+		{"inner", image.Rect(1, 1, 3, 4)},
+		{"single pixel", image.Rect(3, 3, 4, 4)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			icon := NewIconFromImage(src.SubImage(tt.rect))
+
+			if int(icon.Width) != tt.rect.Dx() || int(icon.Height) != tt.rect.Dy() {
+				t.Fatalf("size = %dx%d, want %dx%d", icon.Width, icon.Height, tt.rect.Dx(), tt.rect.Dy())
+			}
+
+			var want []byte
+			for y := tt.rect.Min.Y; y < tt.rect.Max.Y; y++ {
+				for x := tt.rect.Min.X; x < tt.rect.Max.X; x++ {
+					want = append(want, 0xff, uint8(x), uint8(y), 0x80)
+				}
+			}
+
+			if !bytes.Equal(icon.Bytes, want) {
+				t.Errorf("Bytes = %v, want %v", icon.Bytes, want)
+			}
+		})
+	}
+}
@@ -1,8 +1,15 @@
 package systray
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"image"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 )
@@ -54,17 +61,139 @@ const (
 	ItemStatusNeedsAttention ItemStatus = "NeedsAttention"
 )
 
-const getProperty = "org.freedesktop.DBus.Properties.Get"
+// DefaultCoalesceWindow is the default duration during which update signals of
+// [Item] are merged into a single update.
+const DefaultCoalesceWindow = 50 * time.Millisecond
+
+// ItemStats contains counters of update signals received by [Item].
+type ItemStats struct {
+	// Number of update signals received.
+	Received uint64
+
+	// Number of signals merged into an already scheduled update.
+	Merged uint64
+
+	// Number of signals that did not result in a notification, because
+	// refetched properties were unchanged or item was closed.
+	Dropped uint64
+
+	// Number of times properties were refetched.
+	Refreshes uint64
+
+	// Number of notifications delivered to [Item.OnUpdate] and [Item.OnChange]
+	// callbacks.
+	Notifications uint64
+}
+
+const (
+	getProperty      = "org.freedesktop.DBus.Properties.Get"
+	getAllProperties = "org.freedesktop.DBus.Properties.GetAll"
+
+	propertiesInterface = "org.freedesktop.DBus.Properties"
+)
+
+// itemProperties lists properties of StatusNotifierItem that are stored in
+// [Item].
+var itemProperties = []string{
+	"Id",
+	"Category",
+	"WindowId",
+	"ItemIsMenu",
+	"Menu",
+	"Title",
+	"ToolTip",
+	"Status",
+	"IconName",
+	"IconPixmap",
+	"OverlayIconName",
+	"OverlayIconPixmap",
+	"AttentionIconName",
+	"AttentionIconPixmap",
+	"AttentionMovieName",
+	"IconThemePath",
+}
+
+// optionalItemProperties lists extensions of StatusNotifierItem that are not
+// implemented by every item. Failures to fetch them are not reported.
+var optionalItemProperties = []string{
+	"WindowId",
+	"Menu",
+	"AttentionMovieName",
+	"IconThemePath",
+}
+
+// itemSignalProperties maps StatusNotifierItem update signals to properties
+// that are refetched when the signal is received.
+var itemSignalProperties = map[string][]string{
+	"NewTitle":         {"Title"},
+	"NewToolTip":       {"ToolTip"},
+	"NewStatus":        {"Status"},
+	"NewIcon":          {"IconName", "IconPixmap"},
+	"NewOverlayIcon":   {"OverlayIconName", "OverlayIconPixmap"},
+	"NewAttentionIcon": {"AttentionIconName", "AttentionIconPixmap", "AttentionMovieName"},
+	"NewIconThemePath": {"IconThemePath"},
+}
 
 // Item represents system tray item and implements [StatusNotifierItem].
 //
 // [StatusNotifierItem]: https://www.freedesktop.org/wiki/Specifications/StatusNotifierItem/StatusNotifierItem/
 type Item struct {
 	conn       *dbus.Conn
-	signals    chan *dbus.Signal
+	dispatcher *signalDispatcher
+	handlers   []*signalHandler
 	object     dbus.BusObject
 	uniqueName string
 	onUpdate   func()
+	onChange   func(*ItemUpdate)
+
+	// Whether the item does not support GetAll or responds to it with an
+	// empty result.
+	noGetAll bool
+
+	// Whether the item does not implement ProvideXdgActivationToken.
+	noActivationToken bool
+
+	// Process that owns the item, resolved on demand.
+	process *Process
+
+	// Stable identifier of the item, computed on demand.
+	key string
+
+	// Status transitions of the item.
+	history statusHistory
+
+	// Internal update callbacks, e.g. of the host that owns the item.
+	listeners []*itemListener
+
+	// Error callbacks and errors that occurred during initialization.
+	onError        func(*ItemError)
+	errorListeners []func(*ItemError)
+	initErrors     []*ItemError
+	initialized    bool
+
+	// Responsiveness of the item.
+	liveness liveness
+
+	// Callback for changes of arbitrary properties.
+	onPropertyChange func(name string, value dbus.Variant)
+
+	// Icons composited by [Compositor], and revision of the icons that is
+	// incremented whenever they change.
+	composites   map[compositeKey]*image.NRGBA
+	iconRevision uint64
+
+	// Coalescing of update signals.
+	mu             sync.Mutex
+	refreshMu      sync.Mutex
+	closed         bool
+	pending        []string
+	pendingValues  map[string]dbus.Variant
+	pendingSignals uint64
+	timer          *time.Timer
+	lastRefresh    time.Time
+	coalesceWindow time.Duration
+	minInterval    time.Duration
+	stats          ItemStats
 
 	// Unique identifier for the application, such as the application name.
 	ID string
@@ -73,8 +202,16 @@ type Item struct {
 	Title string
 
 	// Extra information that can be visualized by a tooltip.
+	//
+	// Tooltip may contain a subset of HTML markup, see [ParseMarkup].
 	Tooltip string
 
+	// Description of the tooltip, more detailed than Tooltip.
+	//
+	// TooltipDescription may contain a subset of HTML markup, see
+	// [ParseMarkup].
+	TooltipDescription string
+
 	// Category of the item.
 	Category ItemCategory
 
@@ -144,6 +281,10 @@ type Item struct {
 	// D-Bus path to an object which implements the com.canonical.dbusmenu
 	// interface.
 	MenuPath string
+
+	// Additional directory to search for icon themes, or empty string. This is
+	// a KDE extension of the specification, see [IconTheme.Lookup].
+	IconThemePath string
 }
 
 // NewItem returns new [Item] from its unique D-Bus name.
@@ -154,63 +295,28 @@ func NewItem(conn *dbus.Conn, uniqueName string) (*Item, error) {
 // NewItemWithObjectPath returns new [Item] from its unique D-Bus name and
 // allows to specify path of the D-Bus object.
 func NewItemWithObjectPath(conn *dbus.Conn, uniqueName string, objectPath string) (*Item, error) {
-	obj := conn.Object(uniqueName, dbus.ObjectPath(objectPath))
-
-	// Check whether properties can be retrieved.
-	call := obj.Call(getProperty, dbus.Flags(64), StatusNotifierItemInterface, "Title")
-	if call.Err != nil {
-		return nil, fmt.Errorf("failed to resolve item: %w", call.Err)
-	}
-
 	item := Item{
 		conn:       conn,
-		signals:    make(chan *dbus.Signal, 128),
-		object:     obj,
+		object:     conn.Object(uniqueName, dbus.ObjectPath(objectPath)),
 		uniqueName: uniqueName,
 		onUpdate:   func() {},
-	}
-
-	id, err := obj.GetProperty(StatusNotifierItemInterface + ".Id")
-	if err == nil {
-		id.Store(&item.ID)
-	}
-
-	category, err := obj.GetProperty(StatusNotifierItemInterface + ".Category")
-	if err == nil {
-		switch category.String() {
-		case "Communications":
-			item.Category = ItemCategoryCommunications
-		case "SystemServices":
-			item.Category = ItemCategorySystemServices
-		case "Hardware":
-			item.Category = ItemCategoryHardware
-		default:
-			item.Category = ItemCategoryApplicationStatus
-		}
-	}
-
-	windowID, err := obj.GetProperty(StatusNotifierItemInterface + ".WindowId")
-	if err == nil {
-		windowID.Store(&item.WindowID)
-	}
+		onChange:   func(*ItemUpdate) {},
 
-	isMenu, err := obj.GetProperty(StatusNotifierItemInterface + ".ItemIsMenu")
-	if err == nil {
-		isMenu.Store(&item.IsMenu)
+		coalesceWindow: DefaultCoalesceWindow,
+		liveness: liveness{
+			timeout: DefaultCallTimeout,
+		},
 	}
 
-	menu, err := obj.GetProperty(StatusNotifierItemInterface + ".Menu")
-	if err == nil {
-		menu.Store(&item.MenuPath)
+	// Initialize fields of the item.
+	// This also checks whether properties can be retrieved.
+	props, err := item.fetch(itemProperties...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve item: %w", err)
 	}
 
-	// Initialize fields that can be updated via signals.
-	item.updateTitle()
-	item.updateTooltip()
-	item.updateStatus()
-	item.updateIcon()
-	item.updateOverlayIcon()
-	item.updateAttentionIcon()
+	item.apply(props, itemProperties...)
+	item.initialized = true
 
 	// Subscribe to update signals.
 	// This is required to update fields when necessary.
@@ -219,6 +325,22 @@ func NewItemWithObjectPath(conn *dbus.Conn, uniqueName string, objectPath string
 	return &item, nil
 }
 
+// probeItem checks whether object implements StatusNotifierItem, without
+// creating [Item] and subscribing to its signals.
+func probeItem(conn *dbus.Conn, uniqueName string, objectPath string) error {
+	obj := conn.Object(uniqueName, dbus.ObjectPath(objectPath))
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultCallTimeout)
+	defer cancel()
+
+	return obj.CallWithContext(
+		ctx,
+		getProperty,
+		dbus.Flags(64),
+		StatusNotifierItemInterface, "Title",
+	).Err
+}
+
 // BusName returns unique name of the item on D-Bus.
 func (item *Item) BusName() string {
 	return item.uniqueName
@@ -227,22 +349,80 @@ func (item *Item) BusName() string {
 // OnUpdate registers callback that runs whenever item properties are updated.
 //
 // The following signals with the respective update fields are specified by the
-// protocol:
+// protocol (WindowID of the item is refreshed on every signal):
 //
 //   - NewTitle: updates Title of the item
-//   - NewToolTip: updates Tooltip of the item
+//   - NewToolTip: updates Tooltip and TooltipDescription of the item
 //   - NewStatus: updates Status of the item
 //   - NewIcon: updates IconName and IconPixmap of the item.
 //   - NewOverlayIcon: updates OverlayIconName and OverlayIconPixmap of the item.
 //   - NewAttentionIcon: updates AttentionIconName, AttentionIconPixmap, and
 //     AttentionMovieName of the item.
+//   - NewIconThemePath: updates IconThemePath of the item (KDE extension).
+//
+// The callback runs only if at least one field of the item has changed. Use
+// [Item.OnChange] to find out which fields were updated.
 //
 // Graphical tray hosts should redraw representation of the item when its
 // OnUpdate callback is called.
 func (item *Item) OnUpdate(callback func()) {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
 	item.onUpdate = callback
 }
 
+// OnChange registers callback that runs whenever item properties are updated.
+// Unlike [Item.OnUpdate], the callback receives [ItemUpdate] that describes
+// which fields have changed, along with their old and new values.
+//
+// Signals that do not change any field of the item do not trigger the
+// callback.
+func (item *Item) OnChange(callback func(update *ItemUpdate)) {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	item.onChange = callback
+}
+
+// SetCoalesceWindow sets duration during which update signals are merged.
+// Signals received within the window result in a single refetch of properties
+// and a single notification.
+//
+// Zero disables coalescing, so that every signal is handled immediately. The
+// default value is [DefaultCoalesceWindow].
+func (item *Item) SetCoalesceWindow(window time.Duration) {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	item.coalesceWindow = max(window, 0)
+}
+
+// SetMaxUpdateRate limits how many times per second properties of the item are
+// refetched in response to update signals. Signals that exceed the limit are
+// merged into the next update.
+//
+// Zero or negative rate removes the limit, which is the default.
+func (item *Item) SetMaxUpdateRate(rate float64) {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	if rate <= 0 {
+		item.minInterval = 0
+		return
+	}
+
+	item.minInterval = time.Duration(float64(time.Second) / rate)
+}
+
+// Stats returns counters of update signals received by the item.
+func (item *Item) Stats() ItemStats {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	return item.stats
+}
+
 // Menu returns [Menu] object associated with item.
 func (item *Item) Menu() (*Menu, error) {
 	return NewMenu(item.conn, item.uniqueName, item.MenuPath)
@@ -256,9 +436,8 @@ func (item *Item) Menu() (*Menu, error) {
 // The x and y parameters are in screen coordinates and is to be considered a
 // hint to the item about where to show the context menu.
 func (item *Item) ContextMenu(x, y int) error {
-	return item.object.Call(
+	return item.call(
 		StatusNotifierItemInterface+".ContextMenu",
-		dbus.Flags(64),
 		x, y,
 	).Err
 }
@@ -272,9 +451,8 @@ func (item *Item) ContextMenu(x, y int) error {
 // The x and y parameters are in screen coordinates and is to be considered a
 // hint to the item where to show eventual windows (if any).
 func (item *Item) Activate(x, y int) error {
-	return item.object.Call(
+	return item.call(
 		StatusNotifierItemInterface+".Activate",
-		dbus.Flags(64),
 		x, y,
 	).Err
 }
@@ -289,26 +467,80 @@ func (item *Item) Activate(x, y int) error {
 // The x and y parameters are in screen coordinates and is to be considered a
 // hint to the item where to show eventual windows (if any).
 func (item *Item) SecondaryActivate(x, y int) error {
-	return item.object.Call(
+	return item.call(
 		StatusNotifierItemInterface+".SecondaryActivate",
-		dbus.Flags(64),
 		x, y,
 	).Err
 }
 
+// ActivateWithToken is like [Item.Activate], but provides XDG activation token
+// to the item before activation. See [Item.ProvideXdgActivationToken].
+//
+// The item is activated even if the token cannot be provided, since a window
+// without focus is better than no window. Errors of both calls are joined.
+func (item *Item) ActivateWithToken(x, y int, token string) error {
+	tokenErr := item.ProvideXdgActivationToken(token)
+
+	return errors.Join(tokenErr, item.Activate(x, y))
+}
+
+// SecondaryActivateWithToken is like [Item.SecondaryActivate], but provides
+// XDG activation token to the item before activation. See
+// [Item.ProvideXdgActivationToken] and [Item.ActivateWithToken].
+func (item *Item) SecondaryActivateWithToken(x, y int, token string) error {
+	tokenErr := item.ProvideXdgActivationToken(token)
+
+	return errors.Join(tokenErr, item.SecondaryActivate(x, y))
+}
+
+// ProvideXdgActivationToken passes [XDG activation] token to the item. Under
+// Wayland, windows shown by the item in response to activation request receive
+// focus only if the token was provided beforehand.
+//
+// This method is a KDE extension of the specification. If the item does not
+// implement it, the call is skipped and no error is returned. Subsequent calls
+// are skipped without contacting the item.
+//
+// [XDG activation]: https://wayland.app/protocols/xdg-activation-v1
+func (item *Item) ProvideXdgActivationToken(token string) error {
+	item.mu.Lock()
+	unsupported := item.noActivationToken
+	item.mu.Unlock()
+
+	if unsupported {
+		return nil
+	}
+
+	err := item.call(
+		StatusNotifierItemInterface+".ProvideXdgActivationToken",
+		token,
+	).Err
+
+	if isUnknownMethodError(err) {
+		item.mu.Lock()
+		item.noActivationToken = true
+		item.mu.Unlock()
+
+		return nil
+	}
+
+	return err
+}
+
 // Scroll emits a scroll event on the status notifier item.
 //
 // This is caused from input such as mouse wheel over the graphical
 // representation of the item.
 //
 // The delta parameter represent the amount of scroll. The orientation
-// parameter represent orientation of the scroll request and its valid values
-// are "horizontal" and "vertical".
-func (item *Item) Scroll(delta int, orientation string) error {
-	return item.object.Call(
+// parameter represent orientation of the scroll request.
+//
+// Use [ScrollAccumulator] to convert fractional or high-resolution deltas into
+// steps and throttle scroll events.
+func (item *Item) Scroll(delta int, orientation ScrollOrientation) error {
+	return item.call(
 		StatusNotifierItemInterface+".Scroll",
-		dbus.Flags(64),
-		delta, orientation,
+		delta, string(orientation),
 	).Err
 }
 
@@ -316,212 +548,653 @@ func (item *Item) Scroll(delta int, orientation string) error {
 //
 // This method must be called when item is being unregistered from the system tray.
 func (item *Item) close() {
-	item.conn.RemoveMatchSignal(
-		dbus.WithMatchInterface(StatusNotifierItemInterface),
-		dbus.WithMatchMember("NewTitle"),
-		dbus.WithMatchSender(item.uniqueName),
-	)
+	item.mu.Lock()
+	if item.closed {
+		item.mu.Unlock()
+		return
+	}
+	item.closed = true
+	if item.timer != nil {
+		item.timer.Stop()
+		item.timer = nil
+	}
+	if item.liveness.stopPing != nil {
+		close(item.liveness.stopPing)
+		item.liveness.stopPing = nil
+	}
+	item.liveness.onChange = nil
+	item.mu.Unlock()
 
-	item.conn.RemoveMatchSignal(
-		dbus.WithMatchInterface(StatusNotifierItemInterface),
-		dbus.WithMatchMember("NewToolTip"),
-		dbus.WithMatchSender(item.uniqueName),
-	)
+	for _, rule := range item.matchRules() {
+		item.conn.RemoveMatchSignal(rule...)
+	}
 
-	item.conn.RemoveMatchSignal(
-		dbus.WithMatchInterface(StatusNotifierItemInterface),
-		dbus.WithMatchMember("NewStatus"),
-		dbus.WithMatchSender(item.uniqueName),
-	)
+	for _, handler := range item.handlers {
+		item.dispatcher.unregister(handler)
+	}
+	item.dispatcher.release()
 
-	item.conn.RemoveMatchSignal(
-		dbus.WithMatchInterface(StatusNotifierItemInterface),
-		dbus.WithMatchMember("NewIcon"),
-		dbus.WithMatchSender(item.uniqueName),
-	)
+	item.mu.Lock()
+	item.onUpdate = nil
+	item.onChange = nil
+	item.listeners = nil
+	item.onError = nil
+	item.errorListeners = nil
+	item.onPropertyChange = nil
+	item.mu.Unlock()
+}
 
-	item.conn.RemoveMatchSignal(
-		dbus.WithMatchInterface(StatusNotifierItemInterface),
-		dbus.WithMatchMember("NewOverlayIcon"),
-		dbus.WithMatchSender(item.uniqueName),
-	)
+// subscribe subscribes to update signals of the item.
+func (item *Item) subscribe() {
+	for _, rule := range item.matchRules() {
+		item.conn.AddMatchSignal(rule...)
+	}
+
+	item.dispatcher = acquireDispatcher(item.conn)
+
+	path := item.object.Path()
+	item.handlers = []*signalHandler{
+		item.dispatcher.register(item.uniqueName, path, StatusNotifierItemInterface, item.handleSignal),
+		item.dispatcher.register(item.uniqueName, path, propertiesInterface, item.handlePropertiesChanged),
+	}
+}
 
-	item.conn.RemoveMatchSignal(
-		dbus.WithMatchInterface(StatusNotifierItemInterface),
-		dbus.WithMatchMember("NewAttentionIcon"),
+// matchRules returns match rules for signals of the item:
+//   - update signals of org.kde.StatusNotifierItem, such as NewTitle
+//   - org.freedesktop.DBus.Properties.PropertiesChanged
+func (item *Item) matchRules() [][]dbus.MatchOption {
+	rules := make([][]dbus.MatchOption, 0, len(itemSignalProperties)+1)
+
+	for member := range itemSignalProperties {
+		rules = append(rules, []dbus.MatchOption{
+			dbus.WithMatchInterface(StatusNotifierItemInterface),
+			dbus.WithMatchMember(member),
+			dbus.WithMatchSender(item.uniqueName),
+			dbus.WithMatchObjectPath(item.object.Path()),
+		})
+	}
+
+	// Some implementations announce changes only with the standard
+	// PropertiesChanged signal.
+	rules = append(rules, []dbus.MatchOption{
+		dbus.WithMatchInterface(propertiesInterface),
+		dbus.WithMatchMember("PropertiesChanged"),
 		dbus.WithMatchSender(item.uniqueName),
-	)
+		dbus.WithMatchObjectPath(item.object.Path()),
+		dbus.WithMatchArg(0, StatusNotifierItemInterface),
+	})
 
-	item.conn.RemoveSignal(item.signals)
-	close(item.signals)
+	return rules
+}
 
-	item.onUpdate = nil
+// handleSignal schedules refetch of properties associated with the update
+// signal.
+//
+// Signals received within the coalescing window, or before the update rate
+// allows another refetch, are merged into a single update.
+func (item *Item) handleSignal(signal *dbus.Signal) {
+	member, ok := strings.CutPrefix(signal.Name, StatusNotifierItemInterface+".")
+	if !ok {
+		return
+	}
+
+	names, ok := itemSignalProperties[member]
+	if !ok {
+		return
+	}
+
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	item.stats.Received++
+
+	// Window of the item may change at any time, e.g. when the application
+	// recreates its main window, but there is no dedicated signal for it.
+	item.schedule(append(slices.Clip(names), "WindowId"))
 }
 
-func (item *Item) subscribe() {
-	item.conn.AddMatchSignal(
-		dbus.WithMatchInterface(StatusNotifierItemInterface),
-		dbus.WithMatchMember("NewTitle"),
-		dbus.WithMatchSender(item.uniqueName),
-	)
+// handlePropertiesChanged handles the
+// org.freedesktop.DBus.Properties.PropertiesChanged signal.
+//
+// Changed values included in the signal are applied without refetching, and
+// invalidated properties are refetched, as if an update signal was received.
+// Both are queued as a pending update, so that the handler never waits for a
+// refetch in progress.
+func (item *Item) handlePropertiesChanged(signal *dbus.Signal) {
+	if signal.Path != item.object.Path() || len(signal.Body) != 3 {
+		return
+	}
 
-	item.conn.AddMatchSignal(
-		dbus.WithMatchInterface(StatusNotifierItemInterface),
-		dbus.WithMatchMember("NewToolTip"),
-		dbus.WithMatchSender(item.uniqueName),
-	)
+	if iface, ok := signal.Body[0].(string); !ok || iface != StatusNotifierItemInterface {
+		return
+	}
 
-	item.conn.AddMatchSignal(
-		dbus.WithMatchInterface(StatusNotifierItemInterface),
-		dbus.WithMatchMember("NewStatus"),
-		dbus.WithMatchSender(item.uniqueName),
-	)
+	changed, ok := signal.Body[1].(map[string]dbus.Variant)
+	if !ok {
+		return
+	}
 
-	item.conn.AddMatchSignal(
-		dbus.WithMatchInterface(StatusNotifierItemInterface),
-		dbus.WithMatchMember("NewIcon"),
-		dbus.WithMatchSender(item.uniqueName),
-	)
+	invalidated, ok := signal.Body[2].([]string)
+	if !ok {
+		return
+	}
 
-	item.conn.AddMatchSignal(
-		dbus.WithMatchInterface(StatusNotifierItemInterface),
-		dbus.WithMatchMember("NewOverlayIcon"),
-		dbus.WithMatchSender(item.uniqueName),
-	)
+	if len(changed) == 0 && len(invalidated) == 0 {
+		return
+	}
 
-	item.conn.AddMatchSignal(
-		dbus.WithMatchInterface(StatusNotifierItemInterface),
-		dbus.WithMatchMember("NewAttentionIcon"),
-		dbus.WithMatchSender(item.uniqueName),
-	)
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	item.stats.Received++
 
-	item.conn.Signal(item.signals)
+	if item.pendingValues == nil {
+		item.pendingValues = make(map[string]dbus.Variant, len(changed))
+	}
+	maps.Copy(item.pendingValues, changed)
+
+	item.schedule(invalidated)
+}
+
+// schedule adds properties to the pending update and schedules its refetch.
+//
+// The caller must hold item.mu.
+func (item *Item) schedule(names []string) {
+	if item.closed {
+		item.stats.Dropped++
+		return
+	}
+
+	for _, name := range names {
+		if !slices.Contains(item.pending, name) {
+			item.pending = append(item.pending, name)
+		}
+	}
+
+	item.pendingSignals++
+
+	if item.timer != nil {
+		item.stats.Merged++
+		return
+	}
+
+	delay := item.coalesceWindow
+
+	if item.minInterval > 0 {
+		delay = max(delay, time.Until(item.lastRefresh.Add(item.minInterval)))
+	}
+
+	if delay <= 0 {
+		go item.flush()
+		return
+	}
+
+	item.timer = time.AfterFunc(delay, item.flush)
+}
+
+// flush refetches pending properties and notifies callbacks if any of them has
+// changed.
+func (item *Item) flush() {
+	item.refreshMu.Lock()
+	defer item.refreshMu.Unlock()
+
+	item.mu.Lock()
+	names := item.pending
+	values := item.pendingValues
+	signals := item.pendingSignals
+	item.pending = nil
+	item.pendingValues = nil
+	item.pendingSignals = 0
+	item.timer = nil
+	item.lastRefresh = time.Now()
+	item.mu.Unlock()
+
+	if len(names) == 0 && len(values) == 0 {
+		return
+	}
+
+	props := make(map[string]dbus.Variant, len(names)+len(values))
+
+	if len(names) > 0 {
+		fetched, _ := item.fetch(names...)
+		maps.Copy(props, fetched)
+
+		item.mu.Lock()
+		item.stats.Refreshes++
+		item.mu.Unlock()
+	}
+
+	// Values received with PropertiesChanged are applied unless the property
+	// was refetched, since refetched value is at least as recent.
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		if !slices.Contains(names, name) {
+			props[name] = values[name]
+			names = append(names, name)
+		}
+	}
+
+	item.notify(item.apply(props, names...), signals)
+}
+
+// notify runs update callbacks if update is not empty. Parameter signals is the
+// number of signals that resulted in the update.
+func (item *Item) notify(update *ItemUpdate, signals uint64) {
+	item.mu.Lock()
+	if item.closed || (update.IsEmpty() && len(update.values) == 0) {
+		item.stats.Dropped += signals
+		item.mu.Unlock()
+		return
+	}
+	onUpdate, onChange := item.onUpdate, item.onChange
+	onPropertyChange := item.onPropertyChange
+	listeners := item.listeners
+	if !update.IsEmpty() {
+		item.stats.Notifications++
+	}
+	item.mu.Unlock()
+
+	if !update.IsEmpty() {
+		for _, listener := range listeners {
+			listener.onUpdate(update)
+		}
 
-	go func() {
-		for signal := range item.signals {
-			if signal.Sender != item.uniqueName {
+		onUpdate()
+		onChange(update)
+	}
+
+	if onPropertyChange != nil {
+		for _, name := range slices.Sorted(maps.Keys(update.values)) {
+			onPropertyChange(name, update.values[name])
+		}
+	}
+}
+
+// itemListener is an internal update callback registered with [Item.listen].
+// Callbacks are compared by pointer to remove them.
+type itemListener struct {
+	onUpdate func(*ItemUpdate)
+}
+
+// listen registers internal callback that runs whenever item properties are
+// updated. Unlike callbacks set by [Item.OnUpdate] and [Item.OnChange],
+// internal callbacks do not replace each other. The returned function removes
+// the callback.
+func (item *Item) listen(onUpdate func(*ItemUpdate)) (unlisten func()) {
+	listener := &itemListener{onUpdate}
+
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	item.listeners = append(item.listeners, listener)
+
+	return func() {
+		item.mu.Lock()
+		defer item.mu.Unlock()
+
+		// The slice is copied, since notify iterates over it without the lock.
+		item.listeners = slices.DeleteFunc(slices.Clone(item.listeners), func(other *itemListener) bool {
+			return other == listener
+		})
+	}
+}
+
+// fetch retrieves values of properties with the given names.
+//
+// All properties are requested in a single GetAll call. If the item does not
+// implement GetAll properly, properties are retrieved one by one. In this case
+// an error is returned only if none of the properties can be retrieved.
+//
+// Errors of individual properties are reported to error callbacks.
+func (item *Item) fetch(names ...string) (map[string]dbus.Variant, error) {
+	if !item.noGetAll {
+		var props map[string]dbus.Variant
+
+		err := item.call(
+			getAllProperties,
+			StatusNotifierItemInterface,
+		).Store(&props)
+		if err == nil && len(props) > 0 {
+			return props, nil
+		}
+
+		malformed := err == nil
+		if malformed {
+			err = fmt.Errorf("empty response")
+		}
+
+		item.reportError("GetAll", "", err)
+
+		// Do not repeat the GetAll call for implementations that do not
+		// support it. Other errors, such as timeouts, may be transient.
+		if malformed || isUnsupportedError(err) {
+			item.noGetAll = true
+		}
+	}
+
+	props := make(map[string]dbus.Variant, len(names))
+
+	var firstErr error
+
+	for _, name := range names {
+		var value dbus.Variant
+
+		err := item.call(getProperty, StatusNotifierItemInterface, name).Store(&value)
+		if err != nil {
+			if slices.Contains(optionalItemProperties, name) {
 				continue
 			}
 
-			item.handleSignal(signal)
-			item.onUpdate()
+			item.reportError("Get", name, err)
+
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
 		}
-	}()
+
+		props[name] = value
+	}
+
+	if len(props) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+
+	return props, nil
 }
 
-func (item *Item) handleSignal(signal *dbus.Signal) {
-	switch signal.Name {
-	case StatusNotifierItemInterface + ".NewTitle":
-		item.updateTitle()
-	case StatusNotifierItemInterface + ".NewToolTip":
-		item.updateTooltip()
-	case StatusNotifierItemInterface + ".NewStatus":
-		item.updateStatus()
-	case StatusNotifierItemInterface + ".NewIcon":
-		item.updateIcon()
-	case StatusNotifierItemInterface + ".NewOverlayIcon":
-		item.updateOverlayIcon()
-	case StatusNotifierItemInterface + ".NewAttentionIcon":
-		item.updateAttentionIcon()
-	}
-}
-
-// updateTitle initializes or updates Title of the item.
-func (item *Item) updateTitle() {
-	title, err := item.object.GetProperty(StatusNotifierItemInterface + ".Title")
-	if err == nil {
-		title.Store(&item.Title)
-	}
-}
-
-// updateTooltip initializes or updates Tooltip of the item.
-func (item *Item) updateTooltip() {
-	tooltip, err := item.object.GetProperty(StatusNotifierItemInterface + ".ToolTip")
-	if err == nil {
-		// Format of tooltip is as follows
-		//
-		//  [<icon-name>, <icon>, <tooltip>, <description>]
-		//
-		// We are interested in the 3rd item, as it is a text representation of the
-		// tooltip.
-		value := tooltip.Value().([]any)
-
-		if len(value) >= 3 {
-			tooltipStr, ok := value[2].(string)
-			if ok {
-				item.Tooltip = tooltipStr
+// apply stores properties with the given names from props in the item.
+// Properties missing from props are left intact.
+//
+// Properties that cannot be decoded are reported to error callbacks.
+func (item *Item) apply(props map[string]dbus.Variant, names ...string) *ItemUpdate {
+	update := &ItemUpdate{}
+
+	for _, name := range names {
+		value, exists := props[name]
+		if !exists {
+			continue
+		}
+
+		// Values of properties that are not stored in the item, such as
+		// vendor-specific ones, are passed to property callbacks as is.
+		if !slices.Contains(itemProperties, name) {
+			update.setValue(name, value)
+			continue
+		}
+
+		changes, err := item.setProperty(name, value)
+		if err != nil {
+			item.reportError("Decode", name, err)
+		}
+
+		if len(changes) == 0 {
+			continue
+		}
+
+		update.setValue(name, value)
+
+		for _, change := range changes {
+			if change.Field == ItemFieldStatus {
+				item.recordStatus(change)
 			}
+
+			update.add(change)
 		}
 	}
+
+	item.invalidateComposites(update)
+
+	return update
 }
 
-// updateStatus initializes or updates Status of the item.
-func (item *Item) updateStatus() {
-	status, err := item.object.GetProperty(StatusNotifierItemInterface + ".Status")
-	if err == nil {
-		switch status.String() {
-		case "Passive":
-			item.Status = ItemStatusPassive
-		case "NeedsAttention":
-			item.Status = ItemStatusNeedsAttention
+// setProperty stores value of StatusNotifierItem property in the respective
+// fields of the item. It returns the applied changes, which are empty if value
+// is equal to the current one.
+//
+// If value cannot be decoded, an error is returned. Icon sets with some invalid
+// icons are still applied, in which case both changes and error are returned.
+func (item *Item) setProperty(name string, value dbus.Variant) ([]*ItemChange, error) {
+	if name == "ToolTip" {
+		return item.setTooltip(value.Value())
+	}
+
+	change, err := item.setSingleProperty(name, value)
+	if change == nil {
+		return nil, err
+	}
+
+	return []*ItemChange{change}, err
+}
+
+// setTooltip stores title and description of the tooltip from value of the
+// ToolTip property.
+func (item *Item) setTooltip(value any) ([]*ItemChange, error) {
+	title, description, err := tooltipFromDBusProperty(value)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]*ItemChange, 0, 2)
+
+	if change, _ := setField(&item.Tooltip, ItemFieldTooltip, title); change != nil {
+		changes = append(changes, change)
+	}
+
+	if change, _ := setField(&item.TooltipDescription, ItemFieldTooltipDescription, description); change != nil {
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// setSingleProperty stores value of StatusNotifierItem property that
+// corresponds to a single field of the item. It returns the applied change, or
+// nil if value is equal to the current one.
+func (item *Item) setSingleProperty(name string, value dbus.Variant) (*ItemChange, error) {
+	switch name {
+	case "Id":
+		return setField(&item.ID, ItemFieldID, value.Value())
+	case "Title":
+		return setField(&item.Title, ItemFieldTitle, value.Value())
+	case "Category":
+		category, ok := value.Value().(string)
+		if !ok {
+			return nil, invalidTypeError(value.Value())
+		}
+		return setField(&item.Category, ItemFieldCategory, itemCategoryFromString(category))
+	case "Status":
+		status, ok := value.Value().(string)
+		if !ok {
+			return nil, invalidTypeError(value.Value())
+		}
+		return setField(&item.Status, ItemFieldStatus, itemStatusFromString(status))
+	case "WindowId":
+		switch windowID := value.Value().(type) {
+		case int32:
+			return setField(&item.WindowID, ItemFieldWindowID, uint32(windowID))
+		case uint32:
+			return setField(&item.WindowID, ItemFieldWindowID, windowID)
+		default:
+			return nil, invalidTypeError(windowID)
+		}
+	case "IconName":
+		return setField(&item.IconName, ItemFieldIconName, value.Value())
+	case "IconPixmap":
+		return setIconSet(&item.IconPixmap, ItemFieldIconPixmap, value.Value())
+	case "OverlayIconName":
+		return setField(&item.OverlayIconName, ItemFieldOverlayIconName, value.Value())
+	case "OverlayIconPixmap":
+		return setIconSet(&item.OverlayIconPixmap, ItemFieldOverlayIconPixmap, value.Value())
+	case "AttentionIconName":
+		return setField(&item.AttentionIconName, ItemFieldAttentionIconName, value.Value())
+	case "AttentionIconPixmap":
+		return setIconSet(&item.AttentionIconPixmap, ItemFieldAttentionIconPixmap, value.Value())
+	case "AttentionMovieName":
+		return setField(&item.AttentionMovieName, ItemFieldAttentionMovieName, value.Value())
+	case "ItemIsMenu":
+		return setField(&item.IsMenu, ItemFieldIsMenu, value.Value())
+	case "Menu":
+		switch menuPath := value.Value().(type) {
+		case dbus.ObjectPath:
+			return setField(&item.MenuPath, ItemFieldMenuPath, string(menuPath))
+		case string:
+			return setField(&item.MenuPath, ItemFieldMenuPath, menuPath)
 		default:
-			item.Status = ItemStatusActive
+			return nil, invalidTypeError(menuPath)
 		}
+	case "IconThemePath":
+		return setField(&item.IconThemePath, ItemFieldIconThemePath, value.Value())
+	}
+
+	return nil, nil
+}
+
+// setField stores value in dst if value differs from the current value of
+// dst. It returns the applied change.
+//
+// If value does not have type T, an error is returned.
+func setField[T comparable](dst *T, field ItemField, value any) (*ItemChange, error) {
+	v, ok := value.(T)
+	if !ok {
+		return nil, invalidTypeError(value)
+	}
+
+	if v == *dst {
+		return nil, nil
+	}
+
+	change := &ItemChange{
+		Field: field,
+		Old:   *dst,
+		New:   v,
 	}
+
+	*dst = v
+
+	return change, nil
 }
 
-// updateIcon initializes or updates IconName and IconPixmap of the item.
-func (item *Item) updateIcon() {
-	iconName, err := item.object.GetProperty(StatusNotifierItemInterface + ".IconName")
-	if err == nil {
-		iconName.Store(&item.IconName)
+// setIconSet stores icon set parsed from value in dst if it differs from the
+// current value of dst. It returns the applied change.
+//
+// If some icons of the set are invalid, the remaining icons are stored and an
+// error is returned along with the change.
+func setIconSet(dst **IconSet, field ItemField, value any) (*ItemChange, error) {
+	iconset, err := DefaultIconCache.iconSet(value)
+	if iconset == nil || iconset.Equal(*dst) {
+		return nil, err
 	}
 
-	iconPixmap, err := item.object.GetProperty(StatusNotifierItemInterface + ".IconPixmap")
-	if err == nil {
-		iconset, err := NewIconSetFromDBusProperty(iconPixmap.Value())
-		if err == nil {
-			item.IconPixmap = iconset
-		}
+	change := &ItemChange{
+		Field: field,
+		Old:   *dst,
+		New:   iconset,
 	}
+
+	*dst = iconset
+
+	return change, err
+}
+
+// invalidTypeError returns error that describes unexpected type of value.
+func invalidTypeError(value any) error {
+	return fmt.Errorf("invalid type %T", value)
 }
 
-// updateOverlayIcon initializes or updates OverlayIconName and
-// OverlayIconPixmap of the item.
-func (item *Item) updateOverlayIcon() {
-	overlayIconName, err := item.object.GetProperty(StatusNotifierItemInterface + ".OverlayIconName")
-	if err == nil {
-		overlayIconName.Store(&item.OverlayIconName)
+// tooltipFromDBusProperty retrieves title and description of the tooltip from
+// value of the ToolTip property.
+func tooltipFromDBusProperty(value any) (string, string, error) {
+	// Format of tooltip is as follows
+	//
+	//  [<icon-name>, <icon>, <title>, <description>]
+	//
+	// We are interested in the 3rd and 4th items, as they are a text
+	// representation of the tooltip. Description is optional for backward
+	// compatibility with implementations that omit it.
+	data, ok := value.([]any)
+	if !ok || len(data) < 3 {
+		return "", "", fmt.Errorf("invalid tooltip format: expected a struct of 4 elements")
 	}
 
-	overlayIconPixmap, err := item.object.GetProperty(StatusNotifierItemInterface + ".OverlayIconPixmap")
-	if err == nil {
-		iconset, err := NewIconSetFromDBusProperty(overlayIconPixmap.Value())
-		if err == nil {
-			item.OverlayIconPixmap = iconset
-		}
+	title, ok := data[2].(string)
+	if !ok {
+		return "", "", fmt.Errorf("invalid tooltip title type: expected string")
+	}
+
+	if len(data) < 4 {
+		return title, "", nil
 	}
+
+	description, ok := data[3].(string)
+	if !ok {
+		return "", "", fmt.Errorf("invalid tooltip description type: expected string")
+	}
+
+	return title, description, nil
 }
 
-// updateAttentionIcon initializes or updates AttentionIconName,
-// AttentionIconPixmap, and AttentionMovieName of the item.
-func (item *Item) updateAttentionIcon() {
-	attentionIconName, err := item.object.GetProperty(StatusNotifierItemInterface + ".AttentionIconName")
-	if err == nil {
-		attentionIconName.Store(&item.AttentionIconName)
+// itemCategoryFromString returns [ItemCategory] from its string
+// representation. Unknown categories default to
+// [ItemCategoryApplicationStatus].
+func itemCategoryFromString(category string) ItemCategory {
+	switch category {
+	case "Communications":
+		return ItemCategoryCommunications
+	case "SystemServices":
+		return ItemCategorySystemServices
+	case "Hardware":
+		return ItemCategoryHardware
+	default:
+		return ItemCategoryApplicationStatus
 	}
+}
 
-	attentionIconPixmap, err := item.object.GetProperty(StatusNotifierItemInterface + ".AttentionIconPixmap")
-	if err == nil {
-		iconset, err := NewIconSetFromDBusProperty(attentionIconPixmap.Value())
-		if err == nil {
-			item.AttentionIconPixmap = iconset
-		}
+// itemStatusFromString returns [ItemStatus] from its string representation.
+// Unknown statuses default to [ItemStatusActive].
+func itemStatusFromString(status string) ItemStatus {
+	switch status {
+	case "Passive":
+		return ItemStatusPassive
+	case "NeedsAttention":
+		return ItemStatusNeedsAttention
+	default:
+		return ItemStatusActive
+	}
+}
+
+// isUnknownMethodError reports whether err indicates that the called method is
+// not implemented by the remote object.
+func isUnknownMethodError(err error) bool {
+	switch dbusErrorName(err) {
+	case "org.freedesktop.DBus.Error.UnknownMethod",
+		"org.freedesktop.DBus.Error.UnknownInterface",
+		"org.freedesktop.DBus.Error.UnknownObject":
+		return true
+	default:
+		return false
 	}
+}
+
+// isUnsupportedError reports whether err indicates that the called method is
+// not implemented or not supported by the remote object.
+func isUnsupportedError(err error) bool {
+	return isUnknownMethodError(err) ||
+		dbusErrorName(err) == "org.freedesktop.DBus.Error.NotSupported"
+}
 
-	attentionMovieName, err := item.object.GetProperty(StatusNotifierItemInterface + ".AttentionMovieName")
-	if err == nil {
-		attentionMovieName.Store(&item.AttentionMovieName)
+// dbusErrorName returns name of the D-Bus error wrapped by err, or empty
+// string if err is not a D-Bus error.
+func dbusErrorName(err error) string {
+	var dbusErr dbus.Error
+	var dbusErrPtr *dbus.Error
+
+	switch {
+	case errors.As(err, &dbusErr):
+		return dbusErr.Name
+	case errors.As(err, &dbusErrPtr):
+		return dbusErrPtr.Name
+	default:
+		return ""
 	}
 }
 
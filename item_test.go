@@ -0,0 +1,247 @@
+package systray
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// waitTitle waits until Title of the item is updated to the given value.
+func waitTitle(tb testing.TB, item *Item, fake *fakeItem, title string) {
+	tb.Helper()
+
+	updated := make(chan struct{}, 1)
+	item.OnUpdate(func() {
+		select {
+		case updated <- struct{}{}:
+		default:
+		}
+	})
+
+	fake.set("Title", title)
+	if err := fake.emit("NewTitle"); err != nil {
+		tb.Fatal(err)
+	}
+
+	select {
+	case <-updated:
+	case <-time.After(5 * time.Second):
+		tb.Fatal("item was not updated")
+	}
+
+	if item.Title != title {
+		tb.Fatalf("Title = %q, want %q", item.Title, title)
+	}
+}
+
+func TestFetchGetAllUnsupported(t *testing.T) {
+	address := startTestBus(t)
+	conn := connectTestBus(t, address)
+
+	fake := newFakeItem(t, address)
+	fake.mu.Lock()
+	fake.getAllErr = dbus.NewError("org.freedesktop.DBus.Error.UnknownMethod", nil)
+	fake.mu.Unlock()
+
+	item, err := NewItem(conn, fake.name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer item.close()
+
+	var errs []*ItemError
+	item.OnError(func(err *ItemError) {
+		errs = append(errs, err)
+	})
+
+	waitTitle(t, item, fake, "Updated")
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if fake.getAllCalls != 1 {
+		t.Errorf("GetAll called %d times, want 1", fake.getAllCalls)
+	}
+
+	// Properties missing from the fake item are optional and must not be
+	// reported.
+	for _, err := range errs {
+		if slices.Contains(optionalItemProperties, err.Property) {
+			t.Errorf("error reported for optional property %s: %v", err.Property, err)
+		}
+	}
+}
+
+func TestFetchGetAllTransientError(t *testing.T) {
+	address := startTestBus(t)
+	conn := connectTestBus(t, address)
+
+	fake := newFakeItem(t, address)
+	fake.mu.Lock()
+	fake.getAllErr = dbus.NewError("org.freedesktop.DBus.Error.NoReply", nil)
+	fake.mu.Unlock()
+
+	item, err := NewItem(conn, fake.name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer item.close()
+
+	fake.mu.Lock()
+	fake.getAllErr = nil
+	fake.mu.Unlock()
+
+	waitTitle(t, item, fake, "Updated")
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if fake.getAllCalls != 2 {
+		t.Errorf("GetAll called %d times, want 2", fake.getAllCalls)
+	}
+}
+
+func BenchmarkFetch(b *testing.B) {
+	benchmarks := []struct {
+		name     string
+		noGetAll bool
+	}{
+		{"GetAll", false},
+		{"Get", true},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			address := startTestBus(b)
+			conn := connectTestBus(b, address)
+			fake := newFakeItem(b, address)
+
+			item, err := NewItem(conn, fake.name())
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer item.close()
+
+			item.noGetAll = bm.noGetAll
+
+			b.ResetTimer()
+
+			for range b.N {
+				if _, err := item.fetch(itemProperties...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestSignalsCoalescedIntoOneRefresh(t *testing.T) {
+	address := startTestBus(t)
+	fake := newFakeItem(t, address)
+
+	item, err := NewItem(connectTestBus(t, address), fake.name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer item.close()
+
+	// The window is long enough for all signals to arrive before the refresh.
+	item.SetCoalesceWindow(200 * time.Millisecond)
+
+	updates := make(chan struct{}, 16)
+	item.OnUpdate(func() { updates <- struct{}{} })
+
+	fake.set("Title", "Burst")
+
+	signals := []string{"NewTitle", "NewIcon", "NewToolTip", "NewTitle"}
+	for _, member := range signals {
+		if err := fake.emit(member); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-updates:
+	case <-time.After(5 * time.Second):
+		t.Fatal("item was not updated")
+	}
+
+	if item.Title != "Burst" {
+		t.Errorf("Title = %q, want %q", item.Title, "Burst")
+	}
+
+	want := ItemStats{Received: 4, Merged: 3, Refreshes: 1, Notifications: 1}
+	if stats := item.Stats(); stats != want {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+
+	// Signal that does not change properties is dropped after the refresh.
+	item.SetCoalesceWindow(0)
+
+	if err := fake.emit("NewTitle"); err != nil {
+		t.Fatal(err)
+	}
+
+	want = ItemStats{Received: 5, Merged: 3, Dropped: 1, Refreshes: 2, Notifications: 1}
+
+	for range 100 {
+		if item.Stats() == want {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if stats := item.Stats(); stats != want {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+
+	select {
+	case <-updates:
+		t.Error("unchanged properties were notified")
+	default:
+	}
+}
+
+func TestActivateWithTokenError(t *testing.T) {
+	address := startTestBus(t)
+	fake := newFakeItem(t, address)
+	methods := exportFakeItemMethods(t, fake)
+	methods.rejectToken(dbus.NewError("org.example.Error.Token", []any{"token rejected"}))
+
+	item, err := NewItem(connectTestBus(t, address), fake.name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer item.close()
+
+	tests := []struct {
+		method   string
+		activate func(x, y int, token string) error
+	}{
+		{"Activate", item.ActivateWithToken},
+		{"SecondaryActivate", item.SecondaryActivateWithToken},
+	}
+
+	for _, tt := range tests {
+		err := tt.activate(1, 2, "token")
+
+		var dbusErr dbus.Error
+		if !errors.As(err, &dbusErr) || dbusErr.Name != "org.example.Error.Token" {
+			t.Errorf("%s: error = %v, want token error", tt.method, err)
+		}
+
+		if call := methods.next(t, 5*time.Second); call.method != "ProvideXdgActivationToken" {
+			t.Errorf("%s: first call %s, want ProvideXdgActivationToken", tt.method, call.method)
+		}
+
+		// The item is activated even though the token was rejected.
+		call := methods.next(t, 5*time.Second)
+		if want := []any{int32(1), int32(2)}; call.method != tt.method || !slices.Equal(call.args, want) {
+			t.Errorf("call %s%v, want %s%v", call.method, call.args, tt.method, want)
+		}
+	}
+}
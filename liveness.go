@@ -0,0 +1,235 @@
+package systray
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// DefaultCallTimeout is the default timeout of D-Bus calls made by [Item].
+const DefaultCallTimeout = 5 * time.Second
+
+// UnresponsiveThreshold is the number of consecutive failed calls after which
+// [Item] is considered unresponsive.
+const UnresponsiveThreshold = 2
+
+type ItemLiveness int
+
+// [Item] liveness states.
+const (
+	// The item replies to calls in time.
+	ItemLivenessResponsive ItemLiveness = iota
+
+	// The item failed to reply to several consecutive calls, e.g. because the
+	// application is frozen. Visualizations may choose to grey out such items.
+	ItemLivenessUnresponsive
+)
+
+func (l ItemLiveness) String() string {
+	switch l {
+	case ItemLivenessUnresponsive:
+		return "unresponsive"
+	default:
+		return "responsive"
+	}
+}
+
+// ItemLatency contains statistics of D-Bus calls made to [Item].
+type ItemLatency struct {
+	// Latency of the last call.
+	Last time.Duration
+
+	// Exponentially weighted moving average of call latency.
+	Average time.Duration
+
+	// Number of calls made to the item.
+	Calls uint64
+
+	// Number of calls that timed out or were not delivered.
+	Failures uint64
+
+	// Number of failed calls since the last successful one.
+	ConsecutiveFailures uint64
+}
+
+// liveness tracks responsiveness of the item.
+type liveness struct {
+	state    ItemLiveness
+	latency  ItemLatency
+	timeout  time.Duration
+	onChange func(ItemLiveness)
+	stopPing chan struct{}
+}
+
+// Liveness returns whether the item is currently responsive.
+func (item *Item) Liveness() ItemLiveness {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	return item.liveness.state
+}
+
+// IsResponsive reports whether the item is currently responsive.
+func (item *Item) IsResponsive() bool {
+	return item.Liveness() == ItemLivenessResponsive
+}
+
+// Latency returns statistics of D-Bus calls made to the item.
+func (item *Item) Latency() ItemLatency {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	return item.liveness.latency
+}
+
+// OnLivenessChange registers callback that runs whenever the item becomes
+// responsive or unresponsive.
+//
+// Graphical tray hosts may grey out representation of unresponsive items.
+func (item *Item) OnLivenessChange(callback func(state ItemLiveness)) {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	item.liveness.onChange = callback
+}
+
+// SetTimeout sets timeout of D-Bus calls made to the item, including property
+// reads. Calls that time out are treated as failures.
+//
+// Zero or negative timeout disables it. The default value is
+// [DefaultCallTimeout].
+func (item *Item) SetTimeout(timeout time.Duration) {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	item.liveness.timeout = max(timeout, 0)
+}
+
+// SetPingInterval enables periodic liveness checks of the item with
+// org.freedesktop.DBus.Peer.Ping. Zero or negative interval disables them,
+// which is the default.
+func (item *Item) SetPingInterval(interval time.Duration) {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	if item.liveness.stopPing != nil {
+		close(item.liveness.stopPing)
+		item.liveness.stopPing = nil
+	}
+
+	if interval <= 0 || item.closed {
+		return
+	}
+
+	stop := make(chan struct{})
+	item.liveness.stopPing = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				item.Ping()
+			}
+		}
+	}()
+}
+
+// Ping checks whether the item is responsive with
+// org.freedesktop.DBus.Peer.Ping.
+func (item *Item) Ping() error {
+	return item.call("org.freedesktop.DBus.Peer.Ping").Err
+}
+
+// call calls method of the item with timeout and records its latency.
+func (item *Item) call(method string, args ...any) *dbus.Call {
+	item.mu.Lock()
+	timeout := item.liveness.timeout
+	item.mu.Unlock()
+
+	ctx := context.Background()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	call := item.object.CallWithContext(ctx, method, dbus.Flags(64), args...)
+	item.recordCall(time.Since(start), call.Err)
+
+	return call
+}
+
+// recordCall updates latency statistics and liveness state of the item after
+// a call.
+func (item *Item) recordCall(latency time.Duration, err error) {
+	item.mu.Lock()
+
+	stats := &item.liveness.latency
+	stats.Calls++
+	stats.Last = latency
+
+	if stats.Average == 0 {
+		stats.Average = latency
+	} else {
+		stats.Average = (stats.Average*7 + latency) / 8
+	}
+
+	state := ItemLivenessResponsive
+
+	if isDeliveryError(err) {
+		stats.Failures++
+		stats.ConsecutiveFailures++
+
+		if stats.ConsecutiveFailures < UnresponsiveThreshold {
+			state = item.liveness.state
+		} else {
+			state = ItemLivenessUnresponsive
+		}
+	} else {
+		stats.ConsecutiveFailures = 0
+	}
+
+	changed := state != item.liveness.state
+	item.liveness.state = state
+	onChange := item.liveness.onChange
+
+	item.mu.Unlock()
+
+	if changed && onChange != nil {
+		onChange(state)
+	}
+}
+
+// isDeliveryError reports whether err indicates that the call did not reach
+// the remote object or it did not reply. Errors returned by the remote object
+// itself, such as unknown method, mean that the object is responsive.
+func isDeliveryError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, dbus.ErrClosed) {
+		return true
+	}
+
+	switch dbusErrorName(err) {
+	case "org.freedesktop.DBus.Error.NoReply",
+		"org.freedesktop.DBus.Error.Timeout",
+		"org.freedesktop.DBus.Error.TimedOut",
+		"org.freedesktop.DBus.Error.ServiceUnknown",
+		"org.freedesktop.DBus.Error.NameHasNoOwner",
+		"org.freedesktop.DBus.Error.Disconnected":
+		return true
+	default:
+		return false
+	}
+}
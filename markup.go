@@ -0,0 +1,436 @@
+package systray
+
+import (
+	"html"
+	"net/url"
+	"strings"
+)
+
+// Span is a run of text with uniform style.
+type Span struct {
+	// Text of the span. Line breaks are represented as "\n".
+	Text string
+
+	// Whether text is bold.
+	Bold bool
+
+	// Whether text is italic.
+	Italic bool
+
+	// Whether text is underlined.
+	Underline bool
+
+	// Target of the link, if span is a part of a link.
+	Link string
+
+	// Source of the image, if span is an image. Text of image spans is the
+	// alternative text of the image.
+	Image string
+}
+
+// Markup is text with a subset of HTML markup, parsed into styled spans.
+//
+// Tooltips of StatusNotifierItem may contain the following subset of HTML:
+// <b>, <i>, <u>, <br>, <p>, <a href>, and <img src>. Other tags are removed,
+// keeping their text content, except <script> and <style> that are removed
+// along with their content. Links with schemes other than http, https, and
+// mailto, as well as images that are not local files, are removed as well.
+type Markup struct {
+	Spans []Span
+}
+
+// ParseMarkup parses text with a subset of HTML markup. Plain text is parsed as
+// well, in which case HTML entities are decoded, and characters that do not
+// form a tag are kept as is.
+func ParseMarkup(text string) *Markup {
+	p := markupParser{}
+	p.parse(text)
+	p.trimTrailingSpace()
+
+	return &Markup{
+		Spans: p.spans,
+	}
+}
+
+// PlainText returns text of the markup without any styling. Images are
+// replaced with their alternative text.
+func (m *Markup) PlainText() string {
+	var sb strings.Builder
+
+	for _, span := range m.Spans {
+		sb.WriteString(span.Text)
+	}
+
+	return sb.String()
+}
+
+// Pango returns the markup converted to [Pango markup]. Images are replaced
+// with their alternative text, since Pango does not support images. Links are
+// underlined, since Pango markup has no links. Use [Markup.GTKLabel] for
+// labels that support links.
+//
+// [Pango markup]: https://docs.gtk.org/Pango/pango_markup.html
+func (m *Markup) Pango() string {
+	return m.pango(false)
+}
+
+// GTKLabel returns the markup converted to Pango markup extended with <a href>
+// tags, which is accepted by GtkLabel. Other Pango consumers reject such
+// markup entirely, see [Markup.Pango].
+func (m *Markup) GTKLabel() string {
+	return m.pango(true)
+}
+
+// pango converts the markup to Pango markup, with links as <a href> tags if
+// links is true, or underlined otherwise.
+func (m *Markup) pango(links bool) string {
+	var sb strings.Builder
+
+	for _, span := range m.Spans {
+		text := escapePango(span.Text)
+		isLink := span.Link != "" && span.Image == ""
+
+		if span.Bold {
+			text = "<b>" + text + "</b>"
+		}
+
+		if span.Italic {
+			text = "<i>" + text + "</i>"
+		}
+
+		if span.Underline || (isLink && !links) {
+			text = "<u>" + text + "</u>"
+		}
+
+		if isLink && links {
+			text = `<a href="` + escapePango(span.Link) + `">` + text + "</a>"
+		}
+
+		sb.WriteString(text)
+	}
+
+	return sb.String()
+}
+
+// escapePango escapes characters that have special meaning in Pango markup.
+func escapePango(text string) string {
+	return strings.NewReplacer(
+		"&", "&amp;",
+		"<", "&lt;",
+		">", "&gt;",
+		`"`, "&quot;",
+		"'", "&apos;",
+	).Replace(text)
+}
+
+// markupParser converts HTML subset into spans.
+type markupParser struct {
+	spans     []Span
+	bold      int
+	italic    int
+	underline int
+	links     []string
+
+	// Name of the tag whose content is skipped, e.g. script.
+	skip string
+}
+
+// parse parses text and appends resulting spans.
+func (p *markupParser) parse(text string) {
+	for text != "" {
+		idx := strings.IndexByte(text, '<')
+		if idx < 0 {
+			p.text(text)
+			return
+		}
+
+		p.text(text[:idx])
+		text = text[idx:]
+
+		end := strings.IndexByte(text, '>')
+		if end < 0 || !isTagStart(text) {
+			// Not a tag, e.g. "a < b".
+			p.text("<")
+			text = text[1:]
+			continue
+		}
+
+		p.tag(text[1:end])
+		text = text[end+1:]
+	}
+}
+
+// isTagStart reports whether text that starts with "<" is a tag.
+func isTagStart(text string) bool {
+	if len(text) < 2 {
+		return false
+	}
+
+	c := text[1]
+
+	return c == '/' || c == '!' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// text appends text with the current style.
+func (p *markupParser) text(text string) {
+	if text == "" || p.skip != "" {
+		return
+	}
+
+	text = html.UnescapeString(text)
+
+	// Whitespace is collapsed, as in HTML.
+	collapsed := strings.Join(strings.Fields(text), " ")
+
+	if strings.TrimLeft(text, " \t\r\n") != text && !p.atLineStart() {
+		collapsed = " " + collapsed
+	}
+
+	if strings.TrimRight(text, " \t\r\n") != text && !strings.HasSuffix(collapsed, " ") {
+		collapsed += " "
+	}
+
+	if collapsed == "" || (collapsed == " " && p.atLineStart()) {
+		return
+	}
+
+	p.appendSpan(Span{Text: collapsed})
+}
+
+// atLineStart reports whether text is empty or ends with whitespace.
+func (p *markupParser) atLineStart() bool {
+	if len(p.spans) == 0 {
+		return true
+	}
+
+	last := p.spans[len(p.spans)-1].Text
+
+	return strings.HasSuffix(last, "\n") || strings.HasSuffix(last, " ")
+}
+
+// appendSpan appends span with the current style, merging it with the
+// previous span if their styles are equal.
+func (p *markupParser) appendSpan(span Span) {
+	span.Bold = p.bold > 0
+	span.Italic = p.italic > 0
+	span.Underline = p.underline > 0
+
+	if len(p.links) > 0 {
+		span.Link = p.links[len(p.links)-1]
+	}
+
+	if n := len(p.spans); n > 0 && span.Image == "" {
+		last := &p.spans[n-1]
+
+		if last.Image == "" &&
+			last.Bold == span.Bold &&
+			last.Italic == span.Italic &&
+			last.Underline == span.Underline &&
+			last.Link == span.Link {
+			last.Text += span.Text
+			return
+		}
+	}
+
+	p.spans = append(p.spans, span)
+}
+
+// trimTrailingSpace removes whitespace and line breaks at the end of text.
+func (p *markupParser) trimTrailingSpace() {
+	for n := len(p.spans); n > 0; n = len(p.spans) {
+		last := &p.spans[n-1]
+		if last.Image != "" {
+			return
+		}
+
+		last.Text = strings.TrimRight(last.Text, " \n")
+		if last.Text != "" {
+			return
+		}
+
+		p.spans = p.spans[:n-1]
+	}
+}
+
+// lineBreak appends line break, unless text already ends with one.
+func (p *markupParser) lineBreak(force bool) {
+	if p.skip != "" || len(p.spans) == 0 {
+		return
+	}
+
+	last := p.spans[len(p.spans)-1].Text
+	if !force && strings.HasSuffix(last, "\n") {
+		return
+	}
+
+	p.appendSpan(Span{Text: "\n"})
+}
+
+// tag handles content of a tag between "<" and ">".
+func (p *markupParser) tag(content string) {
+	// Comments, doctype, etc.
+	if strings.HasPrefix(content, "!") {
+		return
+	}
+
+	closing := strings.HasPrefix(content, "/")
+	content = strings.TrimPrefix(content, "/")
+	content = strings.TrimSuffix(strings.TrimSpace(content), "/")
+
+	name, attrs := content, ""
+	if idx := strings.IndexAny(content, " \t\r\n"); idx >= 0 {
+		name, attrs = content[:idx], content[idx+1:]
+	}
+	name = strings.ToLower(name)
+
+	if p.skip != "" {
+		if closing && name == p.skip {
+			p.skip = ""
+		}
+		return
+	}
+
+	delta := 1
+	if closing {
+		delta = -1
+	}
+
+	switch name {
+	case "script", "style":
+		if !closing {
+			p.skip = name
+		}
+	case "b", "strong":
+		p.bold = max(p.bold+delta, 0)
+	case "i", "em":
+		p.italic = max(p.italic+delta, 0)
+	case "u":
+		p.underline = max(p.underline+delta, 0)
+	case "br":
+		p.lineBreak(true)
+	case "p", "div":
+		p.lineBreak(false)
+	case "a":
+		if closing {
+			if len(p.links) > 0 {
+				p.links = p.links[:len(p.links)-1]
+			}
+			return
+		}
+
+		p.links = append(p.links, sanitizeLink(parseAttributes(attrs)["href"]))
+	case "img":
+		if closing {
+			return
+		}
+
+		attributes := parseAttributes(attrs)
+
+		src := sanitizeImage(attributes["src"])
+		if src == "" {
+			p.text(attributes["alt"])
+			return
+		}
+
+		p.appendSpan(Span{
+			Text:  attributes["alt"],
+			Image: src,
+		})
+	}
+}
+
+// parseAttributes parses attributes of a tag, such as
+//
+//	href="https://example.com" title='Example' hidden
+//
+// Names of attributes are converted to lower case, values are unescaped.
+func parseAttributes(attrs string) map[string]string {
+	result := make(map[string]string)
+
+	for {
+		attrs = strings.TrimSpace(attrs)
+		if attrs == "" {
+			return result
+		}
+
+		end := strings.IndexAny(attrs, "= \t\n")
+		if end < 0 {
+			result[strings.ToLower(attrs)] = ""
+			return result
+		}
+
+		name := strings.ToLower(attrs[:end])
+		attrs = strings.TrimSpace(attrs[end:])
+
+		if !strings.HasPrefix(attrs, "=") {
+			result[name] = ""
+			continue
+		}
+
+		attrs = strings.TrimSpace(attrs[1:])
+
+		var value string
+
+		if attrs != "" && (attrs[0] == '"' || attrs[0] == '\'') {
+			quote := attrs[0]
+			closing := strings.IndexByte(attrs[1:], quote)
+			if closing < 0 {
+				value, attrs = attrs[1:], ""
+			} else {
+				value, attrs = attrs[1:closing+1], attrs[closing+2:]
+			}
+		} else {
+			end := strings.IndexAny(attrs, " \t\n")
+			if end < 0 {
+				end = len(attrs)
+			}
+			value, attrs = attrs[:end], attrs[end:]
+		}
+
+		result[name] = html.UnescapeString(value)
+	}
+}
+
+// sanitizeLink returns link if its scheme is safe to open, empty string
+// otherwise.
+func sanitizeLink(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return ""
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return u.String()
+	default:
+		return ""
+	}
+}
+
+// sanitizeImage returns path of the image if it is a local file, empty string
+// otherwise. Remote images are not loaded to avoid leaking information.
+func sanitizeImage(src string) string {
+	src = strings.TrimSpace(src)
+
+	if strings.HasPrefix(src, "/") {
+		return src
+	}
+
+	u, err := url.Parse(src)
+	if err != nil || u.Scheme != "file" || u.Path == "" {
+		return ""
+	}
+
+	return u.Path
+}
+
+// TooltipMarkup returns tooltip of the item parsed with [ParseMarkup].
+func (item *Item) TooltipMarkup() *Markup {
+	return ParseMarkup(item.Tooltip)
+}
+
+// TooltipDescriptionMarkup returns tooltip description of the item parsed with
+// [ParseMarkup].
+func (item *Item) TooltipDescriptionMarkup() *Markup {
+	return ParseMarkup(item.TooltipDescription)
+}
@@ -0,0 +1,101 @@
+package systray
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMarkup(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		spans []Span
+	}{
+		{
+			name:  "plain text",
+			text:  "a < b > c",
+			spans: []Span{{Text: "a < b > c"}},
+		},
+		{
+			name:  "entities",
+			text:  "Tom &amp; Jerry &lt;3 &#169; &quot;x&quot;",
+			spans: []Span{{Text: `Tom & Jerry <3 © "x"`}},
+		},
+		{
+			name: "styles",
+			text: "<b>bold</b> <i>italic</i><br/><u>under</u>",
+			spans: []Span{
+				{Text: "bold", Bold: true},
+				{Text: " "},
+				{Text: "italic", Italic: true},
+				{Text: "\n"},
+				{Text: "under", Underline: true},
+			},
+		},
+		{
+			name:  "script and style",
+			text:  "a<script>alert('x')</script>b<style>p{color:red}</style>c",
+			spans: []Span{{Text: "abc"}},
+		},
+		{
+			name:  "unknown tags",
+			text:  "<font color=red>red</font>",
+			spans: []Span{{Text: "red"}},
+		},
+		{
+			name:  "unclosed tags",
+			text:  "<b>bold <i>both",
+			spans: []Span{{Text: "bold ", Bold: true}, {Text: "both", Bold: true, Italic: true}},
+		},
+		{
+			name:  "unterminated tag",
+			text:  "a <b",
+			spans: []Span{{Text: "a <b"}},
+		},
+		{
+			name:  "link",
+			text:  `<a href="https://example.com/?a=1&amp;b=2">site</a>`,
+			spans: []Span{{Text: "site", Link: "https://example.com/?a=1&b=2"}},
+		},
+		{
+			name:  "javascript link",
+			text:  `<a href="javascript:alert(1)">click</a>`,
+			spans: []Span{{Text: "click"}},
+		},
+		{
+			name:  "local image",
+			text:  `<img src="file:///tmp/a.png" alt="A">`,
+			spans: []Span{{Text: "A", Image: "/tmp/a.png"}},
+		},
+		{
+			name:  "remote image",
+			text:  `<img src="https://example.com/track.png" alt="A">`,
+			spans: []Span{{Text: "A"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseMarkup(tt.text).Spans
+			if !reflect.DeepEqual(got, tt.spans) {
+				t.Errorf("ParseMarkup(%q) = %+v, want %+v", tt.text, got, tt.spans)
+			}
+		})
+	}
+}
+
+func TestMarkupPango(t *testing.T) {
+	markup := ParseMarkup(`<b>a &amp; b</b> <a href="https://example.com">link</a>`)
+
+	if got, want := markup.Pango(), "<b>a &amp; b</b> <u>link</u>"; got != want {
+		t.Errorf("Pango() = %q, want %q", got, want)
+	}
+
+	if got, want := markup.GTKLabel(), `<b>a &amp; b</b> <a href="https://example.com">link</a>`; got != want {
+		t.Errorf("GTKLabel() = %q, want %q", got, want)
+	}
+
+	if got, want := markup.PlainText(), "a & b link"; got != want {
+		t.Errorf("PlainText() = %q, want %q", got, want)
+	}
+}
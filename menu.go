@@ -2,6 +2,7 @@ package systray
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/godbus/dbus/v5"
@@ -106,18 +107,30 @@ func getRemovedProperties(data any) ([]*RemovedProperties, error) {
 type Menu struct {
 	uniqueName         string
 	conn               *dbus.Conn
-	signals            chan *dbus.Signal
+	dispatcher         *signalDispatcher
+	handler            *signalHandler
 	object             dbus.BusObject
 	onLayoutUpdate     func(int32)
 	onPropertiesUpdate func([]*UpdatedProperties, []*RemovedProperties)
 	onActivate         func(int32)
 
+	// Icons of layout nodes cached by [Menu.NodeIcon], and revisions of icon
+	// properties of nodes and of the layout.
+	mu             sync.Mutex
+	icons          map[nodeIconKey]*Icon
+	nodeRevisions  map[int32]uint64
+	layoutRevision uint64
+
 	// Version of the com.canonical.dbusmenu interface.
 	Version uint
 
 	// Status of the application, whether it requires attention. Possible values
 	// are "normal" (for most cases) and "notice" (a higher priority to be shown).
 	Status string
+
+	// Additional directories to search for icon themes, used to resolve icon
+	// names of layout nodes. See [Menu.NodeIcon].
+	IconThemePath []string
 }
 
 // NewMenu retrieves menu of item with specified name and path.
@@ -133,7 +146,6 @@ func NewMenu(conn *dbus.Conn, name, path string) (*Menu, error) {
 	menu := Menu{
 		uniqueName:         name,
 		conn:               conn,
-		signals:            make(chan *dbus.Signal),
 		object:             obj,
 		onLayoutUpdate:     func(int32) {},
 		onActivate:         func(int32) {},
@@ -150,6 +162,11 @@ func NewMenu(conn *dbus.Conn, name, path string) (*Menu, error) {
 		status.Store(&menu.Status)
 	}
 
+	iconThemePath, err := obj.GetProperty(MenuInterface + ".IconThemePath")
+	if err == nil {
+		iconThemePath.Store(&menu.IconThemePath)
+	}
+
 	if err := menu.subscribe(); err != nil {
 		return nil, fmt.Errorf("menu: %w", err)
 	}
@@ -260,12 +277,18 @@ func (m *Menu) AboutToShow(target *LayoutNode) (bool, error) {
 // Parameter id of the callback is ID of the parent node for the nodes that
 // have changed. If it is zero, the entire layout is updated.
 func (m *Menu) OnLayoutUpdate(callback func(id int32)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.onLayoutUpdate = callback
 }
 
 // OnPropertiesUpdate registers callback that runs whenever properties of
 // layout nodes are updated.
 func (m *Menu) OnPropertiesUpdate(callback func(updated []*UpdatedProperties, removed []*RemovedProperties)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.onPropertiesUpdate = callback
 }
 
@@ -274,41 +297,30 @@ func (m *Menu) OnPropertiesUpdate(callback func(updated []*UpdatedProperties, re
 //
 // Parameter id of callback is ID of a specific node that should be activated.
 func (m *Menu) OnActivate(callback func(id int32)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.onActivate = callback
 }
 
 // Close unsubscribes from menu update signals.
 func (m *Menu) Close() error {
-	if err := m.conn.RemoveMatchSignal(
-		dbus.WithMatchInterface(MenuInterface),
-		dbus.WithMatchMember("ItemsPropertiesUpdated"),
-		dbus.WithMatchSender(m.uniqueName),
-	); err != nil {
-		return err
-	}
-
-	if err := m.conn.RemoveMatchSignal(
-		dbus.WithMatchInterface(MenuInterface),
-		dbus.WithMatchMember("LayoutUpdated"),
-		dbus.WithMatchSender(m.uniqueName),
-	); err != nil {
-		return err
-	}
-
-	if err := m.conn.RemoveMatchSignal(
-		dbus.WithMatchInterface(MenuInterface),
-		dbus.WithMatchMember("ItemActivationRequested"),
-		dbus.WithMatchSender(m.uniqueName),
-	); err != nil {
-		return err
+	for _, rule := range m.matchRules() {
+		if err := m.conn.RemoveMatchSignal(rule...); err != nil {
+			return err
+		}
 	}
 
-	m.conn.RemoveSignal(m.signals)
-	close(m.signals)
+	m.dispatcher.unregister(m.handler)
+	m.dispatcher.release()
 
+	// Signals queued before the handler was unregistered may still be
+	// handled, so that callbacks are reset under the lock.
+	m.mu.Lock()
 	m.onLayoutUpdate = nil
 	m.onPropertiesUpdate = nil
 	m.onActivate = nil
+	m.mu.Unlock()
 
 	return nil
 }
@@ -318,52 +330,52 @@ func (m *Menu) Close() error {
 //   - com.canonical.dbusmenu.LayoutUpdated
 //   - com.canonical.dbusmenu.ItemActivationRequested
 func (m *Menu) subscribe() error {
-	if err := m.conn.AddMatchSignal(
-		dbus.WithMatchInterface(MenuInterface),
-		dbus.WithMatchMember("ItemsPropertiesUpdated"),
-		dbus.WithMatchSender(m.uniqueName),
-	); err != nil {
-		return err
-	}
-
-	if err := m.conn.AddMatchSignal(
-		dbus.WithMatchInterface(MenuInterface),
-		dbus.WithMatchMember("LayoutUpdated"),
-		dbus.WithMatchSender(m.uniqueName),
-	); err != nil {
-		return err
-	}
-
-	if err := m.conn.AddMatchSignal(
-		dbus.WithMatchInterface(MenuInterface),
-		dbus.WithMatchMember("ItemActivationRequested"),
-		dbus.WithMatchSender(m.uniqueName),
-	); err != nil {
-		return err
-	}
-
-	m.conn.Signal(m.signals)
-
-	go func() {
-		for signal := range m.signals {
-			if signal.Sender != m.uniqueName {
-				continue
-			}
-
-			switch signal.Name {
-			case MenuInterface + ".ItemsPropertiesUpdated":
-				m.handleItemPropertiesUpdated(signal)
-			case MenuInterface + ".LayoutUpdated":
-				m.handleLayoutUpdated(signal)
-			case MenuInterface + ".ItemActivationRequested":
-				m.handleItemActivationRequested(signal)
-			}
+	for _, rule := range m.matchRules() {
+		if err := m.conn.AddMatchSignal(rule...); err != nil {
+			return err
 		}
-	}()
+	}
+
+	m.dispatcher = acquireDispatcher(m.conn)
+	m.handler = m.dispatcher.register(m.uniqueName, m.object.Path(), MenuInterface, m.handleSignal)
 
 	return nil
 }
 
+// matchRules returns match rules for signals of the menu.
+func (m *Menu) matchRules() [][]dbus.MatchOption {
+	members := []string{
+		"ItemsPropertiesUpdated",
+		"LayoutUpdated",
+		"ItemActivationRequested",
+	}
+
+	rules := make([][]dbus.MatchOption, 0, len(members))
+
+	for _, member := range members {
+		rules = append(rules, []dbus.MatchOption{
+			dbus.WithMatchInterface(MenuInterface),
+			dbus.WithMatchMember(member),
+			dbus.WithMatchSender(m.uniqueName),
+			dbus.WithMatchObjectPath(m.object.Path()),
+		})
+	}
+
+	return rules
+}
+
+// handleSignal handles signals of the menu.
+func (m *Menu) handleSignal(signal *dbus.Signal) {
+	switch signal.Name {
+	case MenuInterface + ".ItemsPropertiesUpdated":
+		m.handleItemPropertiesUpdated(signal)
+	case MenuInterface + ".LayoutUpdated":
+		m.handleLayoutUpdated(signal)
+	case MenuInterface + ".ItemActivationRequested":
+		m.handleItemActivationRequested(signal)
+	}
+}
+
 // handleItemPropertiesUpdated handles the
 // com.canonical.dbusmenu.ItemsPropertiesUpdated signal.
 func (m *Menu) handleItemPropertiesUpdated(signal *dbus.Signal) {
@@ -381,7 +393,15 @@ func (m *Menu) handleItemPropertiesUpdated(signal *dbus.Signal) {
 		return
 	}
 
-	m.onPropertiesUpdate(updatedProperties, removedProperties)
+	m.invalidateNodeIcons(updatedProperties, removedProperties)
+
+	m.mu.Lock()
+	onPropertiesUpdate := m.onPropertiesUpdate
+	m.mu.Unlock()
+
+	if onPropertiesUpdate != nil {
+		onPropertiesUpdate(updatedProperties, removedProperties)
+	}
 }
 
 // handleLayoutUpdated handles the
@@ -396,7 +416,15 @@ func (m *Menu) handleLayoutUpdated(signal *dbus.Signal) {
 		return
 	}
 
-	m.onLayoutUpdate(nodeID)
+	m.invalidateAllNodeIcons()
+
+	m.mu.Lock()
+	onLayoutUpdate := m.onLayoutUpdate
+	m.mu.Unlock()
+
+	if onLayoutUpdate != nil {
+		onLayoutUpdate(nodeID)
+	}
 }
 
 // handleItemActivationRequested handles the
@@ -411,5 +439,11 @@ func (m *Menu) handleItemActivationRequested(signal *dbus.Signal) {
 		return
 	}
 
-	m.onActivate(nodeID)
+	m.mu.Lock()
+	onActivate := m.onActivate
+	m.mu.Unlock()
+
+	if onActivate != nil {
+		onActivate(nodeID)
+	}
 }
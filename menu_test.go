@@ -0,0 +1,77 @@
+package systray
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestMenu returns menu of the item, closed when the test ends.
+func newTestMenu(t *testing.T, item *Item) (*Menu, chan int32) {
+	t.Helper()
+
+	menu, err := item.Menu()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates := make(chan int32, 16)
+	menu.OnLayoutUpdate(func(id int32) { updates <- id })
+
+	return menu, updates
+}
+
+// waitLayoutUpdate waits for a layout update with the given parent node ID.
+func waitLayoutUpdate(t *testing.T, updates chan int32, want int32) {
+	t.Helper()
+
+	select {
+	case id := <-updates:
+		if id != want {
+			t.Errorf("layout update of node %d, want %d", id, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("layout update was not received")
+	}
+}
+
+func TestMenusOfSameItem(t *testing.T) {
+	address := startTestBus(t)
+	conn := connectTestBus(t, address)
+
+	fake := newFakeItem(t, address)
+	fakeMenu := exportFakeMenu(t, fake)
+
+	item, err := NewItem(conn, fake.name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer item.close()
+
+	first, firstUpdates := newTestMenu(t, item)
+	second, secondUpdates := newTestMenu(t, item)
+	defer second.Close()
+
+	if err := fakeMenu.emit("LayoutUpdated", uint32(1), int32(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	waitLayoutUpdate(t, firstUpdates, 1)
+	waitLayoutUpdate(t, secondUpdates, 1)
+
+	// Closing one menu must not silence the other.
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fakeMenu.emit("LayoutUpdated", uint32(2), int32(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	waitLayoutUpdate(t, secondUpdates, 2)
+
+	select {
+	case id := <-firstUpdates:
+		t.Errorf("closed menu received layout update of node %d", id)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
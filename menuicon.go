@@ -0,0 +1,175 @@
+package systray
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"math"
+)
+
+// Properties of layout nodes that define their icons.
+const (
+	nodeIconName = "icon-name"
+	nodeIconData = "icon-data"
+)
+
+// nodeIconKey identifies icon of layout node in the cache of [Menu].
+type nodeIconKey struct {
+	theme *IconTheme
+	id    int32
+	size  int
+	scale float64
+}
+
+// NodeIcon returns icon of the layout node as [Icon], the same type that is
+// used for icons of [Item].
+//
+// Icon name of the node is resolved with the theme at size and scale, searching
+// IconThemePath of the menu first. If the node has no icon name, or it cannot
+// be resolved, PNG from icon data of the node is decoded. If theme is nil,
+// [DefaultIconTheme] is used.
+//
+// Icons are cached per node until the menu receives ItemsPropertiesUpdated
+// signal that changes icon of the node, or LayoutUpdated signal.
+func (m *Menu) NodeIcon(theme *IconTheme, node *LayoutNode, size int, scale float64) (*Icon, error) {
+	if theme == nil {
+		theme = DefaultIconTheme()
+	}
+
+	key := nodeIconKey{theme, node.ID, size, scale}
+
+	m.mu.Lock()
+	cached, ok := m.icons[key]
+	revision := m.nodeRevisions[node.ID]
+	layoutRevision := m.layoutRevision
+	m.mu.Unlock()
+
+	if ok {
+		return cached, nil
+	}
+
+	icon, err := m.loadNodeIcon(theme, node, size, scale)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Properties of the node changed while the icon was loaded.
+	if revision != m.nodeRevisions[node.ID] || layoutRevision != m.layoutRevision {
+		return icon, nil
+	}
+
+	if m.icons == nil {
+		m.icons = make(map[nodeIconKey]*Icon)
+	}
+	m.icons[key] = icon
+
+	return icon, nil
+}
+
+// loadNodeIcon loads icon of the layout node without caching.
+func (m *Menu) loadNodeIcon(theme *IconTheme, node *LayoutNode, size int, scale float64) (*Icon, error) {
+	var nameErr error
+
+	if name := node.IconName(); name != "" {
+		icon, err := theme.LoadIcon(name, size, int(math.Ceil(scale)), m.IconThemePath...)
+		if err == nil {
+			return icon, nil
+		}
+
+		nameErr = err
+	}
+
+	if data := node.IconData(); len(data) > 0 {
+		icon, err := decodeNodeIconData(data)
+		if err != nil {
+			return nil, fmt.Errorf("menu node %d: icon data: %w", node.ID, err)
+		}
+
+		return icon, nil
+	}
+
+	if nameErr != nil {
+		return nil, fmt.Errorf("menu node %d: %w", node.ID, nameErr)
+	}
+
+	return nil, fmt.Errorf("menu node %d: no icon", node.ID)
+}
+
+// decodeNodeIconData decodes PNG icon data of a layout node. Dimensions are
+// read from the PNG header and checked against [DefaultIconLimits] first, so
+// that a small file declaring a huge image is never decoded.
+func decodeNodeIconData(data []byte) (*Icon, error) {
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode png: %w", err)
+	}
+
+	limits := DefaultIconLimits
+
+	if (limits.MaxWidth > 0 && int64(config.Width) > int64(limits.MaxWidth)) ||
+		(limits.MaxHeight > 0 && int64(config.Height) > int64(limits.MaxHeight)) {
+		return nil, fmt.Errorf("%w: maximum dimensions are %dx%d", ErrPixmapTooLarge, limits.MaxWidth, limits.MaxHeight)
+	}
+
+	// Computed in int64 to avoid overflow on 32-bit platforms.
+	if limits.MaxBytes > 0 && int64(config.Width)*int64(config.Height)*4 > int64(limits.MaxBytes) {
+		return nil, fmt.Errorf("%w: maximum size is %d bytes", ErrPixmapTooLarge, limits.MaxBytes)
+	}
+
+	return DecodePNG(bytes.NewReader(data))
+}
+
+// invalidateNodeIcons discards cached icons of nodes whose icon properties were
+// updated or removed.
+func (m *Menu) invalidateNodeIcons(updated []*UpdatedProperties, removed []*RemovedProperties) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, up := range updated {
+		_, nameUpdated := up.Properties[nodeIconName]
+		_, dataUpdated := up.Properties[nodeIconData]
+
+		if nameUpdated || dataUpdated {
+			m.invalidateNodeIcon(up.NodeID)
+		}
+	}
+
+	for _, rp := range removed {
+		for _, name := range rp.Properties {
+			if name == nodeIconName || name == nodeIconData {
+				m.invalidateNodeIcon(rp.NodeID)
+				break
+			}
+		}
+	}
+}
+
+// invalidateNodeIcon discards cached icons of the node.
+//
+// The caller must hold m.mu.
+func (m *Menu) invalidateNodeIcon(id int32) {
+	if m.nodeRevisions == nil {
+		m.nodeRevisions = make(map[int32]uint64)
+	}
+
+	m.nodeRevisions[id]++
+
+	for key := range m.icons {
+		if key.id == id {
+			delete(m.icons, key)
+		}
+	}
+}
+
+// invalidateAllNodeIcons discards cached icons of all nodes, e.g. when layout
+// of the menu is updated.
+func (m *Menu) invalidateAllNodeIcons() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.layoutRevision++
+	clear(m.icons)
+}
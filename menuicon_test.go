@@ -0,0 +1,174 @@
+package systray
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// testNodeProperties is a node entry of ItemsPropertiesUpdated signal.
+type testNodeProperties struct {
+	ID         int32
+	Properties map[string]dbus.Variant
+}
+
+// testNodeRemovedProperties is a removed entry of ItemsPropertiesUpdated
+// signal.
+type testNodeRemovedProperties struct {
+	ID         int32
+	Properties []string
+}
+
+func TestNodeIconDataLimits(t *testing.T) {
+	limits := DefaultIconLimits
+	defer func() { DefaultIconLimits = limits }()
+
+	DefaultIconLimits = IconLimits{MaxWidth: 32, MaxHeight: 32, MaxBytes: 16 * 16 * 4}
+
+	tests := []struct {
+		name          string
+		width, height int
+		wantErr       error
+	}{
+		{"within limits", 16, 16, nil},
+		{"too wide", 33, 1, ErrPixmapTooLarge},
+		{"too high", 1, 33, ErrPixmapTooLarge},
+		{"too many bytes", 17, 16, ErrPixmapTooLarge},
+	}
+
+	m := &Menu{}
+	theme := NewIconTheme("test", t.TempDir())
+
+	for idx, tt := range tests {
+		node := &LayoutNode{
+			ID:         int32(idx),
+			Properties: map[string]any{nodeIconData: []byte(encodeTestPNG(t, tt.width, tt.height))},
+		}
+
+		icon, err := m.NodeIcon(theme, node, 16, 1)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+
+		if tt.wantErr == nil && (icon.Width != int32(tt.width) || icon.Height != int32(tt.height)) {
+			t.Errorf("%s: size = %dx%d, want %dx%d", tt.name, icon.Width, icon.Height, tt.width, tt.height)
+		}
+	}
+
+	// Data that is not PNG is rejected without decoding.
+	node := &LayoutNode{ID: 100, Properties: map[string]any{nodeIconData: []byte("not png")}}
+	if _, err := m.NodeIcon(theme, node, 16, 1); err == nil {
+		t.Error("invalid icon data was decoded")
+	}
+}
+
+func TestNodeIconCacheInvalidation(t *testing.T) {
+	address := startTestBus(t)
+	conn := connectTestBus(t, address)
+
+	fake := newFakeItem(t, address)
+	fakeMenu := exportFakeMenu(t, fake)
+
+	item, err := NewItem(conn, fake.name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer item.close()
+
+	menu, layoutUpdates := newTestMenu(t, item)
+	defer menu.Close()
+
+	propertiesUpdates := make(chan struct{}, 16)
+	menu.OnPropertiesUpdate(func([]*UpdatedProperties, []*RemovedProperties) {
+		propertiesUpdates <- struct{}{}
+	})
+
+	waitPropertiesUpdate := func() {
+		t.Helper()
+
+		select {
+		case <-propertiesUpdates:
+		case <-time.After(5 * time.Second):
+			t.Fatal("properties update was not received")
+		}
+	}
+
+	theme := NewIconTheme("test", t.TempDir())
+	node := &LayoutNode{
+		ID:         1,
+		Properties: map[string]any{nodeIconData: []byte(encodeTestPNG(t, 16, 16))},
+	}
+
+	load := func() *Icon {
+		t.Helper()
+
+		icon, err := menu.NodeIcon(theme, node, 16, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return icon
+	}
+
+	icon := load()
+	if load() != icon {
+		t.Fatal("icon was not cached")
+	}
+
+	// Updates of other properties or other nodes keep the icon.
+	err = fakeMenu.emit("ItemsPropertiesUpdated",
+		[]testNodeProperties{
+			{1, map[string]dbus.Variant{"label": dbus.MakeVariant("Label")}},
+			{2, map[string]dbus.Variant{nodeIconData: dbus.MakeVariant([]byte{})}},
+		},
+		[]testNodeRemovedProperties{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitPropertiesUpdate()
+
+	if load() != icon {
+		t.Error("icon was invalidated by unrelated update")
+	}
+
+	err = fakeMenu.emit("ItemsPropertiesUpdated",
+		[]testNodeProperties{{1, map[string]dbus.Variant{nodeIconData: dbus.MakeVariant([]byte{})}}},
+		[]testNodeRemovedProperties{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitPropertiesUpdate()
+
+	updated := load()
+	if updated == icon {
+		t.Error("icon was not invalidated by update of icon-data")
+	}
+
+	err = fakeMenu.emit("ItemsPropertiesUpdated",
+		[]testNodeProperties{},
+		[]testNodeRemovedProperties{{1, []string{nodeIconName}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitPropertiesUpdate()
+
+	removed := load()
+	if removed == updated {
+		t.Error("icon was not invalidated by removal of icon-name")
+	}
+
+	if err := fakeMenu.emit("LayoutUpdated", uint32(2), int32(0)); err != nil {
+		t.Fatal(err)
+	}
+	waitLayoutUpdate(t, layoutUpdates, 0)
+
+	if load() == removed {
+		t.Error("icon was not invalidated by layout update")
+	}
+}
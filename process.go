@@ -0,0 +1,154 @@
+package systray
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Process describes the process that owns [Item] on D-Bus.
+type Process struct {
+	// ID of the process.
+	PID uint32
+
+	// Absolute path to the executable of the process. Empty if it cannot be
+	// resolved, e.g. if the process belongs to another user.
+	Executable string
+
+	// Command line arguments of the process, including the program name.
+	Cmdline []string
+
+	// Control group of the process, as listed in /proc/<pid>/cgroup.
+	Cgroup string
+
+	// Name of the systemd unit (service or scope) the process belongs to, if
+	// any. Desktop environments often launch applications in scopes like
+	// app-firefox-1234.scope, which makes Unit a hint about the application.
+	Unit string
+}
+
+// Process returns information about the process that owns the item.
+//
+// The process is resolved once and cached for the lifetime of the item.
+func (item *Item) Process() (*Process, error) {
+	item.mu.Lock()
+	process := item.process
+	timeout := item.liveness.timeout
+	item.mu.Unlock()
+
+	if process != nil {
+		return process, nil
+	}
+
+	ctx := context.Background()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	pid, err := connectionUnixProcessID(ctx, item.conn.BusObject(), item.uniqueName)
+	if err != nil {
+		return nil, fmt.Errorf("process: %w", err)
+	}
+
+	process = newProcess(pid)
+
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	// Another call may have resolved the process in the meantime.
+	if item.process == nil {
+		item.process = process
+	}
+
+	return item.process, nil
+}
+
+// connectionUnixProcessID returns ID of the process that owns name on D-Bus.
+func connectionUnixProcessID(ctx context.Context, bus dbus.BusObject, name string) (uint32, error) {
+	var pid uint32
+
+	err := bus.CallWithContext(
+		ctx,
+		"org.freedesktop.DBus.GetConnectionUnixProcessID",
+		0,
+		name,
+	).Store(&pid)
+	if err != nil {
+		return 0, err
+	}
+
+	return pid, nil
+}
+
+// newProcess returns [Process] with the given PID. Information that cannot be
+// read from procfs is left empty.
+func newProcess(pid uint32) *Process {
+	proc := filepath.Join("/proc", strconv.FormatUint(uint64(pid), 10))
+
+	process := &Process{
+		PID: pid,
+	}
+
+	if exe, err := os.Readlink(filepath.Join(proc, "exe")); err == nil {
+		process.Executable = strings.TrimSuffix(exe, " (deleted)")
+	}
+
+	if cmdline, err := os.ReadFile(filepath.Join(proc, "cmdline")); err == nil {
+		for arg := range bytes.SplitSeq(bytes.TrimRight(cmdline, "\x00"), []byte{0}) {
+			process.Cmdline = append(process.Cmdline, string(arg))
+		}
+	}
+
+	if cgroup, err := os.ReadFile(filepath.Join(proc, "cgroup")); err == nil {
+		process.Cgroup, process.Unit = parseCgroup(string(cgroup))
+	}
+
+	return process
+}
+
+// parseCgroup returns cgroup path and name of the innermost systemd unit from
+// content of /proc/<pid>/cgroup.
+//
+// Format of each line is "<hierarchy-id>:<controllers>:<path>". The unified
+// (cgroup v2) hierarchy is preferred; otherwise the name=systemd hierarchy is
+// used.
+func parseCgroup(content string) (string, string) {
+	var path string
+
+	for line := range strings.SplitSeq(strings.TrimSpace(content), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		if parts[0] == "0" && parts[1] == "" {
+			path = parts[2]
+			break
+		}
+
+		if parts[1] == "name=systemd" {
+			path = parts[2]
+		}
+	}
+
+	segments := strings.Split(path, "/")
+
+	for idx := len(segments) - 1; idx >= 0; idx-- {
+		segment := segments[idx]
+
+		if strings.HasSuffix(segment, ".scope") || strings.HasSuffix(segment, ".service") {
+			return path, segment
+		}
+	}
+
+	return path, ""
+}
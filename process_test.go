@@ -0,0 +1,36 @@
+package systray
+
+import (
+	"os"
+	"testing"
+)
+
+func TestItemProcess(t *testing.T) {
+	address := startTestBus(t)
+	conn := connectTestBus(t, address)
+	fake := newFakeItem(t, address)
+
+	item, err := NewItem(conn, fake.name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer item.close()
+
+	process, err := item.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if process.PID != uint32(os.Getpid()) {
+		t.Errorf("PID = %d, want %d", process.PID, os.Getpid())
+	}
+
+	cached, err := item.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cached != process {
+		t.Error("process was not cached")
+	}
+}
@@ -0,0 +1,123 @@
+package systray
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Property returns value of the item property with the given name. Unlike
+// exported fields of [Item], it provides access to vendor-specific properties.
+//
+// Name is either a property of the org.kde.StatusNotifierItem interface, such
+// as "Title", or a property qualified with its interface, such as
+// "com.example.Vendor.Badge".
+func (item *Item) Property(name string) (dbus.Variant, error) {
+	iface, member := qualifiedName(name)
+
+	var value dbus.Variant
+
+	err := item.call(getProperty, iface, member).Store(&value)
+	if err != nil {
+		return dbus.Variant{}, fmt.Errorf("property %s: %w", name, err)
+	}
+
+	return value, nil
+}
+
+// Properties returns values of all properties of the org.kde.StatusNotifierItem
+// interface implemented by the item, including vendor-specific ones.
+func (item *Item) Properties() (map[string]dbus.Variant, error) {
+	var props map[string]dbus.Variant
+
+	err := item.call(getAllProperties, StatusNotifierItemInterface).Store(&props)
+	if err != nil {
+		return nil, fmt.Errorf("properties: %w", err)
+	}
+
+	return props, nil
+}
+
+// OnPropertyChange registers callback that runs whenever a property of the
+// org.kde.StatusNotifierItem interface changes, including vendor-specific
+// properties announced with org.freedesktop.DBus.Properties.PropertiesChanged.
+//
+// The callback receives name of the property and its new value.
+func (item *Item) OnPropertyChange(callback func(name string, value dbus.Variant)) {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	item.onPropertyChange = callback
+}
+
+// Call calls method of the item and returns values of its reply. Unlike
+// [Item.Activate] and similar methods, it provides access to vendor-specific
+// methods.
+//
+// Method is either a method of the org.kde.StatusNotifierItem interface, such
+// as "Activate", or a method qualified with its interface, such as
+// "com.example.Vendor.Open".
+//
+// The call uses timeout of the item and affects its liveness, see
+// [Item.SetTimeout].
+func (item *Item) Call(method string, args ...any) ([]any, error) {
+	iface, member := qualifiedName(method)
+
+	call := item.call(iface+"."+member, args...)
+	if call.Err != nil {
+		return nil, fmt.Errorf("call %s: %w", method, call.Err)
+	}
+
+	return call.Body, nil
+}
+
+// PropertyAs returns value of the item property with the given name, decoded
+// into type T. See [Item.Property] for the format of name.
+func PropertyAs[T any](item *Item, name string) (T, error) {
+	var result T
+
+	value, err := item.Property(name)
+	if err != nil {
+		return result, err
+	}
+
+	if err := value.Store(&result); err != nil {
+		return result, fmt.Errorf("property %s: %w", name, err)
+	}
+
+	return result, nil
+}
+
+// CallAs calls method of the item and returns the first value of its reply,
+// decoded into type T. See [Item.Call] for the format of method.
+func CallAs[T any](item *Item, method string, args ...any) (T, error) {
+	var result T
+
+	body, err := item.Call(method, args...)
+	if err != nil {
+		return result, err
+	}
+
+	if len(body) == 0 {
+		return result, fmt.Errorf("call %s: empty reply", method)
+	}
+
+	if err := dbus.Store(body[:1], &result); err != nil {
+		return result, fmt.Errorf("call %s: %w", method, err)
+	}
+
+	return result, nil
+}
+
+// qualifiedName splits name of a property or method into interface and
+// member. Unqualified names belong to the org.kde.StatusNotifierItem
+// interface.
+func qualifiedName(name string) (string, string) {
+	iface, member, ok := cutLast(name, ".")
+	if !ok || !strings.Contains(iface, ".") {
+		return StatusNotifierItemInterface, member
+	}
+
+	return iface, member
+}
@@ -0,0 +1,185 @@
+package systray
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// ScaleImage returns img scaled to fit into an image of exactly width x height
+// pixels. Aspect ratio is preserved: the scaled image is centered, and the
+// remaining area is transparent.
+//
+// Downscaling averages all covered source pixels, upscaling interpolates
+// linearly. Colors are blended with premultiplied alpha, so that transparent
+// pixels do not bleed into opaque ones.
+func ScaleImage(img image.Image, width, height int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, max(width, 0), max(height, 0)))
+
+	bounds := img.Bounds()
+	if bounds.Empty() || width <= 0 || height <= 0 {
+		return dst
+	}
+
+	scale := min(
+		float64(width)/float64(bounds.Dx()),
+		float64(height)/float64(bounds.Dy()),
+	)
+
+	fitWidth := min(max(int(math.Round(float64(bounds.Dx())*scale)), 1), width)
+	fitHeight := min(max(int(math.Round(float64(bounds.Dy())*scale)), 1), height)
+
+	scaled := resample(toNRGBA(img), fitWidth, fitHeight)
+
+	offset := image.Pt((width-fitWidth)/2, (height-fitHeight)/2)
+	draw.Draw(dst, scaled.Bounds().Add(offset), scaled, image.Point{}, draw.Src)
+
+	return dst
+}
+
+// toNRGBA returns img as [image.NRGBA] with bounds starting at (0, 0),
+// converting it if necessary.
+func toNRGBA(img image.Image) *image.NRGBA {
+	switch src := img.(type) {
+	case *image.NRGBA:
+		if src.Bounds().Min.Eq(image.Point{}) {
+			return src
+		}
+	case *Icon:
+		return src.Image()
+	}
+
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+
+	return dst
+}
+
+// resample returns src resized to exactly width x height pixels.
+func resample(src *image.NRGBA, width, height int) *image.NRGBA {
+	srcWidth, srcHeight := src.Bounds().Dx(), src.Bounds().Dy()
+
+	// Premultiplied source pixels.
+	pixels := make([]float32, srcWidth*srcHeight*4)
+
+	for y := range srcHeight {
+		for x := range srcWidth {
+			s := src.Pix[y*src.Stride+x*4 : y*src.Stride+x*4+4 : y*src.Stride+x*4+4]
+			d := pixels[(y*srcWidth+x)*4 : (y*srcWidth+x)*4+4 : (y*srcWidth+x)*4+4]
+
+			a := float32(s[3]) / 255
+			d[0] = float32(s[0]) * a
+			d[1] = float32(s[1]) * a
+			d[2] = float32(s[2]) * a
+			d[3] = float32(s[3])
+		}
+	}
+
+	// Horizontal pass: srcWidth x srcHeight -> width x srcHeight.
+	horizontal := make([]float32, width*srcHeight*4)
+	xWeights := resampleWeights(srcWidth, width)
+
+	for y := range srcHeight {
+		for x, weights := range xWeights {
+			d := horizontal[(y*width+x)*4 : (y*width+x)*4+4 : (y*width+x)*4+4]
+
+			for _, w := range weights {
+				s := pixels[(y*srcWidth+w.index)*4 : (y*srcWidth+w.index)*4+4 : (y*srcWidth+w.index)*4+4]
+
+				d[0] += s[0] * w.weight
+				d[1] += s[1] * w.weight
+				d[2] += s[2] * w.weight
+				d[3] += s[3] * w.weight
+			}
+		}
+	}
+
+	// Vertical pass: width x srcHeight -> width x height.
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	yWeights := resampleWeights(srcHeight, height)
+
+	for y, weights := range yWeights {
+		for x := range width {
+			var r, g, b, a float32
+
+			for _, w := range weights {
+				s := horizontal[(w.index*width+x)*4 : (w.index*width+x)*4+4 : (w.index*width+x)*4+4]
+
+				r += s[0] * w.weight
+				g += s[1] * w.weight
+				b += s[2] * w.weight
+				a += s[3] * w.weight
+			}
+
+			d := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4 : y*dst.Stride+x*4+4]
+
+			if a <= 0 {
+				continue
+			}
+
+			alpha := a / 255
+			d[0] = clampUint8(r / alpha)
+			d[1] = clampUint8(g / alpha)
+			d[2] = clampUint8(b / alpha)
+			d[3] = clampUint8(a)
+		}
+	}
+
+	return dst
+}
+
+// resampleWeight is contribution of a source pixel to a destination pixel.
+type resampleWeight struct {
+	index  int
+	weight float32
+}
+
+// resampleWeights returns contributions of source pixels to each destination
+// pixel along a single axis, using a triangle filter. When downscaling, the
+// filter is widened to cover all source pixels that fall into the
+// destination pixel.
+func resampleWeights(srcSize, dstSize int) [][]resampleWeight {
+	ratio := float64(srcSize) / float64(dstSize)
+	support := max(ratio, 1)
+
+	weights := make([][]resampleWeight, dstSize)
+
+	for i := range weights {
+		center := (float64(i)+0.5)*ratio - 0.5
+
+		from := int(math.Ceil(center - support))
+		to := int(math.Floor(center + support))
+
+		var sum float64
+
+		contributions := make([]resampleWeight, 0, to-from+1)
+
+		for j := from; j <= to; j++ {
+			w := 1 - math.Abs(float64(j)-center)/support
+			if w <= 0 {
+				continue
+			}
+
+			contributions = append(contributions, resampleWeight{
+				index:  min(max(j, 0), srcSize-1),
+				weight: float32(w),
+			})
+
+			sum += w
+		}
+
+		for k := range contributions {
+			contributions[k].weight /= float32(sum)
+		}
+
+		weights[i] = contributions
+	}
+
+	return weights
+}
+
+// clampUint8 rounds v and clamps it to the range of uint8.
+func clampUint8(v float32) uint8 {
+	return uint8(min(max(v+0.5, 0), 255))
+}
@@ -0,0 +1,136 @@
+package systray
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// solidTestImage returns image of the given size filled with the color.
+func solidTestImage(width, height int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	return img
+}
+
+func TestScaleImageSize(t *testing.T) {
+	tests := []struct {
+		srcWidth, srcHeight int
+		width, height       int
+	}{
+		{48, 48, 22, 22},
+		{16, 16, 33, 33},
+		{1, 1, 5, 5},
+		{22, 22, 22, 22},
+		{10, 10, 7, 3},
+		{3, 100, 16, 16},
+	}
+
+	red := color.NRGBA{0xff, 0, 0, 0xff}
+
+	for _, tt := range tests {
+		img := ScaleImage(solidTestImage(tt.srcWidth, tt.srcHeight, red), tt.width, tt.height)
+
+		if want := image.Rect(0, 0, tt.width, tt.height); img.Bounds() != want {
+			t.Errorf("%dx%d to %dx%d: bounds = %v, want %v",
+				tt.srcWidth, tt.srcHeight, tt.width, tt.height, img.Bounds(), want)
+		}
+	}
+
+	if img := ScaleImage(image.NewNRGBA(image.Rect(0, 0, 4, 4)), 0, -1); !img.Bounds().Empty() {
+		t.Errorf("bounds = %v, want empty image", img.Bounds())
+	}
+}
+
+func TestScaleImageLetterbox(t *testing.T) {
+	red := color.NRGBA{0xff, 0, 0, 0xff}
+
+	tests := []struct {
+		name          string
+		width, height int
+
+		// Pixels expected to be opaque red and transparent.
+		opaque, transparent []image.Point
+	}{
+		{
+			// 20x10 is fitted into 10x5 and centered vertically at rows 2-6.
+			name:  "wide",
+			width: 20, height: 10,
+			opaque:      []image.Point{{0, 2}, {5, 4}, {9, 6}},
+			transparent: []image.Point{{5, 0}, {5, 1}, {5, 7}, {5, 9}},
+		},
+		{
+			// 10x20 is fitted into 5x10 and centered horizontally at columns 2-6.
+			name:  "tall",
+			width: 10, height: 20,
+			opaque:      []image.Point{{2, 0}, {4, 5}, {6, 9}},
+			transparent: []image.Point{{0, 5}, {1, 5}, {7, 5}, {9, 5}},
+		},
+	}
+
+	for _, tt := range tests {
+		img := ScaleImage(solidTestImage(tt.width, tt.height, red), 10, 10)
+
+		for _, p := range tt.opaque {
+			if c := img.NRGBAAt(p.X, p.Y); c != red {
+				t.Errorf("%s: pixel %v = %v, want %v", tt.name, p, c, red)
+			}
+		}
+
+		for _, p := range tt.transparent {
+			if c := img.NRGBAAt(p.X, p.Y); c.A != 0 {
+				t.Errorf("%s: pixel %v = %v, want transparent", tt.name, p, c)
+			}
+		}
+	}
+}
+
+func TestResampleWeights(t *testing.T) {
+	tests := []struct {
+		srcSize, dstSize int
+	}{
+		{48, 22},
+		{16, 33},
+		{1, 5},
+		{5, 1},
+		{22, 22},
+	}
+
+	for _, tt := range tests {
+		weights := resampleWeights(tt.srcSize, tt.dstSize)
+		if len(weights) != tt.dstSize {
+			t.Errorf("%d to %d: %d pixels, want %d", tt.srcSize, tt.dstSize, len(weights), tt.dstSize)
+			continue
+		}
+
+		for i, contributions := range weights {
+			var sum float64
+
+			for _, c := range contributions {
+				if c.index < 0 || c.index >= tt.srcSize {
+					t.Errorf("%d to %d: pixel %d uses source pixel %d", tt.srcSize, tt.dstSize, i, c.index)
+				}
+
+				sum += float64(c.weight)
+			}
+
+			// Normalized weights keep solid colors intact.
+			if math.Abs(sum-1) > 1e-5 {
+				t.Errorf("%d to %d: weights of pixel %d sum to %f, want 1", tt.srcSize, tt.dstSize, i, sum)
+			}
+		}
+	}
+
+	// Sizes that match copy source pixels as is.
+	for i, contributions := range resampleWeights(4, 4) {
+		if len(contributions) != 1 || contributions[0].index != i {
+			t.Errorf("4 to 4: pixel %d = %v, want source pixel %d", i, contributions, i)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package systray
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+type ScrollOrientation string
+
+// [Item.Scroll] orientations.
+const (
+	ScrollOrientationVertical   ScrollOrientation = "vertical"
+	ScrollOrientationHorizontal ScrollOrientation = "horizontal"
+)
+
+// ScrollAccumulator converts scroll deltas reported by input devices into
+// integer steps expected by [Item.Scroll].
+//
+// Touchpads and high-resolution mouse wheels report many small, often
+// fractional, deltas. ScrollAccumulator sums them up and emits a scroll event
+// once a full step is accumulated. Scroll events are throttled: at most one
+// event per orientation is sent within the throttling interval, steps
+// accumulated in the meantime are sent together at the end of the interval.
+type ScrollAccumulator struct {
+	item     *Item
+	stepSize float64
+	interval time.Duration
+
+	mu         sync.Mutex
+	pending    map[ScrollOrientation]float64
+	lastScroll map[ScrollOrientation]time.Time
+	timers     map[ScrollOrientation]*time.Timer
+}
+
+// NewScrollAccumulator returns a new [ScrollAccumulator] for the item.
+//
+// Parameter stepSize is the amount of delta that corresponds to a single
+// scroll step, e.g. 1 for deltas measured in wheel notches, or 120 for
+// high-resolution wheel events. Non-positive step size defaults to 1.
+//
+// Parameter interval is the minimum duration between scroll events sent to
+// the item. Zero disables throttling.
+func NewScrollAccumulator(item *Item, stepSize float64, interval time.Duration) *ScrollAccumulator {
+	if stepSize <= 0 {
+		stepSize = 1
+	}
+
+	return &ScrollAccumulator{
+		item:       item,
+		stepSize:   stepSize,
+		interval:   max(interval, 0),
+		pending:    make(map[ScrollOrientation]float64),
+		lastScroll: make(map[ScrollOrientation]time.Time),
+		timers:     make(map[ScrollOrientation]*time.Timer),
+	}
+}
+
+// Add accumulates scroll delta in the given orientation.
+//
+// If at least one full step is accumulated and the throttling interval has
+// passed since the previous event, a scroll event is sent immediately and its
+// error is returned. Otherwise, the event is deferred until the end of the
+// interval, and its error is reported to [Item.OnError] callback.
+func (a *ScrollAccumulator) Add(delta float64, orientation ScrollOrientation) error {
+	a.mu.Lock()
+
+	a.pending[orientation] += delta / a.stepSize
+
+	if a.timers[orientation] != nil {
+		a.mu.Unlock()
+		return nil
+	}
+
+	if wait := time.Until(a.lastScroll[orientation].Add(a.interval)); wait > 0 {
+		a.timers[orientation] = time.AfterFunc(wait, func() {
+			a.mu.Lock()
+			a.timers[orientation] = nil
+			a.mu.Unlock()
+
+			if err := a.Flush(orientation); err != nil {
+				a.item.reportError("Scroll", "", err)
+			}
+		})
+
+		a.mu.Unlock()
+		return nil
+	}
+
+	a.mu.Unlock()
+
+	return a.Flush(orientation)
+}
+
+// Flush sends accumulated full steps in the given orientation to the item,
+// regardless of the throttling interval. Fractional remainder is kept.
+func (a *ScrollAccumulator) Flush(orientation ScrollOrientation) error {
+	a.mu.Lock()
+
+	steps := math.Trunc(a.pending[orientation])
+	if steps == 0 {
+		a.mu.Unlock()
+		return nil
+	}
+
+	a.pending[orientation] -= steps
+	a.lastScroll[orientation] = time.Now()
+
+	a.mu.Unlock()
+
+	return a.item.Scroll(int(steps), orientation)
+}
+
+// Reset discards accumulated deltas and cancels deferred scroll events.
+func (a *ScrollAccumulator) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for orientation, timer := range a.timers {
+		if timer != nil {
+			timer.Stop()
+		}
+
+		delete(a.timers, orientation)
+	}
+
+	clear(a.pending)
+}
@@ -0,0 +1,118 @@
+package systray
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// newTestScrollItem returns item backed by a fake item that records method
+// calls.
+func newTestScrollItem(t *testing.T) (*Item, *fakeItemMethods) {
+	t.Helper()
+
+	address := startTestBus(t)
+	fake := newFakeItem(t, address)
+	methods := exportFakeItemMethods(t, fake)
+
+	item, err := NewItem(connectTestBus(t, address), fake.name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(item.close)
+
+	return item, methods
+}
+
+// expectScroll fails the test unless the next call is Scroll with the
+// arguments.
+func expectScroll(t *testing.T, methods *fakeItemMethods, delta int32, orientation ScrollOrientation) {
+	t.Helper()
+
+	call := methods.next(t, 5*time.Second)
+	if want := []any{delta, string(orientation)}; call.method != "Scroll" || !slices.Equal(call.args, want) {
+		t.Errorf("call %s%v, want Scroll%v", call.method, call.args, want)
+	}
+}
+
+func TestScrollAccumulatorFractionalDeltas(t *testing.T) {
+	item, methods := newTestScrollItem(t)
+	a := NewScrollAccumulator(item, 120, 0)
+
+	steps := []struct {
+		delta       float64
+		orientation ScrollOrientation
+
+		// Expected scroll delta, zero if no event is expected.
+		want int32
+	}{
+		{60, ScrollOrientationVertical, 0},
+		{60, ScrollOrientationVertical, 1},
+
+		// Orientations are accumulated separately.
+		{90, ScrollOrientationHorizontal, 0},
+		{-30, ScrollOrientationVertical, 0},
+		{60, ScrollOrientationHorizontal, 1},
+
+		// Remainder is kept after full steps are sent.
+		{-210, ScrollOrientationVertical, -2},
+		{300, ScrollOrientationVertical, 2},
+		{60, ScrollOrientationVertical, 1},
+	}
+
+	for _, step := range steps {
+		// Without throttling, events are sent before Add returns.
+		if err := a.Add(step.delta, step.orientation); err != nil {
+			t.Fatal(err)
+		}
+
+		if step.want != 0 {
+			expectScroll(t, methods, step.want, step.orientation)
+		}
+
+		methods.expectNone(t, 0)
+	}
+
+	// Flush does not send fractional remainder.
+	if err := a.Flush(ScrollOrientationHorizontal); err != nil {
+		t.Fatal(err)
+	}
+	methods.expectNone(t, 0)
+}
+
+func TestScrollAccumulatorThrottling(t *testing.T) {
+	item, methods := newTestScrollItem(t)
+
+	interval := 200 * time.Millisecond
+	a := NewScrollAccumulator(item, 1, interval)
+
+	start := time.Now()
+
+	// The first event is sent immediately.
+	if err := a.Add(1, ScrollOrientationVertical); err != nil {
+		t.Fatal(err)
+	}
+	expectScroll(t, methods, 1, ScrollOrientationVertical)
+
+	// Events within the interval are merged and sent at its end.
+	for range 3 {
+		if err := a.Add(1, ScrollOrientationVertical); err != nil {
+			t.Fatal(err)
+		}
+	}
+	methods.expectNone(t, 0)
+
+	expectScroll(t, methods, 3, ScrollOrientationVertical)
+
+	if elapsed := time.Since(start); elapsed < interval {
+		t.Errorf("deferred event was sent after %v, want at least %v", elapsed, interval)
+	}
+
+	// Reset cancels the deferred event.
+	if err := a.Add(1, ScrollOrientationVertical); err != nil {
+		t.Fatal(err)
+	}
+	a.Reset()
+
+	methods.expectNone(t, 2*interval)
+}
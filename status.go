@@ -0,0 +1,143 @@
+package systray
+
+import "time"
+
+// StatusHistorySize is the number of status transitions stored by [Item].
+// Older transitions are discarded.
+const StatusHistorySize = 32
+
+// StatusTransition describes change of [Item] status.
+type StatusTransition struct {
+	// Status before the transition. Empty for the status the item had when it
+	// was registered.
+	From ItemStatus
+
+	// Status after the transition.
+	To ItemStatus
+
+	// Time of the transition.
+	Time time.Time
+}
+
+// statusHistory is a ring buffer of status transitions.
+type statusHistory struct {
+	transitions [StatusHistorySize]StatusTransition
+	head        int
+	count       int
+
+	// Current status of the item. It is read by methods of [Item] instead of
+	// Item.Status, which is written without holding item.mu.
+	status ItemStatus
+
+	// Number of transitions to NeedsAttention status.
+	attentionCount int
+
+	// Whether host dismissed attention request of the item.
+	attentionDismissed bool
+}
+
+// record stores transition in the history.
+func (h *statusHistory) record(transition StatusTransition) {
+	h.transitions[h.head] = transition
+	h.head = (h.head + 1) % StatusHistorySize
+	h.count = min(h.count+1, StatusHistorySize)
+	h.status = transition.To
+
+	if transition.To == ItemStatusNeedsAttention {
+		h.attentionCount++
+	}
+
+	h.attentionDismissed = false
+}
+
+// last returns the latest transition.
+func (h *statusHistory) last() (StatusTransition, bool) {
+	if h.count == 0 {
+		return StatusTransition{}, false
+	}
+
+	return h.transitions[(h.head-1+StatusHistorySize)%StatusHistorySize], true
+}
+
+// all returns stored transitions from the oldest to the latest.
+func (h *statusHistory) all() []StatusTransition {
+	transitions := make([]StatusTransition, h.count)
+	start := (h.head - h.count + StatusHistorySize) % StatusHistorySize
+
+	for idx := range transitions {
+		transitions[idx] = h.transitions[(start+idx)%StatusHistorySize]
+	}
+
+	return transitions
+}
+
+// StatusHistory returns recent status transitions of the item, from the oldest
+// to the latest. At most [StatusHistorySize] transitions are returned.
+func (item *Item) StatusHistory() []StatusTransition {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	return item.history.all()
+}
+
+// StatusSince returns time when the item entered its current status.
+func (item *Item) StatusSince() time.Time {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	transition, _ := item.history.last()
+	return transition.Time
+}
+
+// AttentionCount returns how many times the item has requested attention, i.e.
+// entered [ItemStatusNeedsAttention] status, since it was registered.
+func (item *Item) AttentionCount() int {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	return item.history.attentionCount
+}
+
+// DismissAttention acknowledges attention request of the item. The item
+// remains in [ItemStatusNeedsAttention] status, but [Item.NeedsAttention]
+// reports false and [Host] does not notify about the item until its next
+// status change.
+func (item *Item) DismissAttention() {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	item.history.attentionDismissed = item.history.status == ItemStatusNeedsAttention
+}
+
+// IsAttentionDismissed reports whether attention request of the item was
+// dismissed with [Item.DismissAttention].
+func (item *Item) IsAttentionDismissed() bool {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	return item.history.attentionDismissed
+}
+
+// NeedsAttention reports whether the item has [ItemStatusNeedsAttention]
+// status and its attention request was not dismissed.
+func (item *Item) NeedsAttention() bool {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	return item.history.status == ItemStatusNeedsAttention && !item.history.attentionDismissed
+}
+
+// recordStatus stores status transition described by change in the history.
+func (item *Item) recordStatus(change *ItemChange) {
+	from, _ := change.Old.(ItemStatus)
+	to, _ := change.New.(ItemStatus)
+
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	item.history.record(StatusTransition{
+		From: from,
+		To:   to,
+		Time: time.Now(),
+	})
+}
@@ -0,0 +1,84 @@
+package systray
+
+import (
+	"testing"
+	"time"
+)
+
+// setTestStatus records change of the item status.
+func setTestStatus(item *Item, from, to ItemStatus) {
+	item.recordStatus(&ItemChange{Field: ItemFieldStatus, Old: from, New: to})
+}
+
+func TestStatusHistoryWrapAround(t *testing.T) {
+	var h statusHistory
+
+	if _, ok := h.last(); ok {
+		t.Error("empty history has the latest transition")
+	}
+
+	start := time.Unix(0, 0)
+	total := StatusHistorySize + 5
+
+	for idx := range total {
+		h.record(StatusTransition{
+			From: ItemStatusActive,
+			To:   ItemStatusPassive,
+			Time: start.Add(time.Duration(idx) * time.Second),
+		})
+	}
+
+	transitions := h.all()
+	if len(transitions) != StatusHistorySize {
+		t.Fatalf("len(all()) = %d, want %d", len(transitions), StatusHistorySize)
+	}
+
+	for idx, transition := range transitions {
+		want := start.Add(time.Duration(total-StatusHistorySize+idx) * time.Second)
+		if !transition.Time.Equal(want) {
+			t.Errorf("transition %d at %v, want %v", idx, transition.Time, want)
+		}
+	}
+
+	last, ok := h.last()
+	if want := start.Add(time.Duration(total-1) * time.Second); !ok || !last.Time.Equal(want) {
+		t.Errorf("last() at %v, want %v", last.Time, want)
+	}
+}
+
+func TestItemDismissAttention(t *testing.T) {
+	item := &Item{}
+
+	setTestStatus(item, "", ItemStatusActive)
+
+	// Attention cannot be dismissed before it is requested.
+	item.DismissAttention()
+	if item.IsAttentionDismissed() {
+		t.Error("attention dismissed in Active status")
+	}
+
+	setTestStatus(item, ItemStatusActive, ItemStatusNeedsAttention)
+	if !item.NeedsAttention() {
+		t.Fatal("NeedsAttention() = false after request")
+	}
+
+	item.DismissAttention()
+	if item.NeedsAttention() || !item.IsAttentionDismissed() {
+		t.Error("attention was not dismissed")
+	}
+
+	// The next status change clears dismissal.
+	setTestStatus(item, ItemStatusNeedsAttention, ItemStatusActive)
+	if item.IsAttentionDismissed() {
+		t.Error("dismissal was kept after status change")
+	}
+
+	setTestStatus(item, ItemStatusActive, ItemStatusNeedsAttention)
+	if !item.NeedsAttention() {
+		t.Error("NeedsAttention() = false after repeated request")
+	}
+
+	if count := item.AttentionCount(); count != 2 {
+		t.Errorf("AttentionCount() = %d, want 2", count)
+	}
+}
@@ -0,0 +1,247 @@
+package systray
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// symbolicSuffix is the suffix of names of symbolic icons.
+const symbolicSuffix = "-symbolic"
+
+// symbolicTolerance is the maximum difference between color components of
+// pixels of a monochrome icon.
+const symbolicTolerance = 24
+
+// symbolicMinAlpha is the minimum alpha of pixels considered by the
+// monochrome heuristic. Fainter pixels are dominated by antialiasing.
+const symbolicMinAlpha = 32
+
+// SymbolicColors are colors used to render symbolic icons. Symbolic SVG icons
+// can mark elements with style classes success, warning, and error, which are
+// rendered with the respective colors. Other elements are rendered with the
+// foreground color.
+type SymbolicColors struct {
+	Foreground color.Color
+	Success    color.Color
+	Warning    color.Color
+	Error      color.Color
+}
+
+// NewSymbolicColors returns [SymbolicColors] with the foreground color and
+// default colors of the style classes, as used by Adwaita.
+func NewSymbolicColors(foreground color.Color) SymbolicColors {
+	return SymbolicColors{
+		Foreground: foreground,
+		Success:    color.NRGBA{0x33, 0xd1, 0x7a, 0xff},
+		Warning:    color.NRGBA{0xf6, 0xd3, 0x2d, 0xff},
+		Error:      color.NRGBA{0xe0, 0x1b, 0x24, 0xff},
+	}
+}
+
+// IsSymbolicName reports whether name is a name of a symbolic icon, i.e. ends
+// with "-symbolic".
+func IsSymbolicName(name string) bool {
+	return strings.HasSuffix(name, symbolicSuffix)
+}
+
+// IsSymbolic reports whether img looks like a symbolic icon: all its visible
+// pixels have the same gray color, and the shape is defined by alpha only.
+// Fully transparent images are not symbolic.
+func IsSymbolic(img image.Image) bool {
+	src := toNRGBA(img)
+
+	var (
+		first   color.NRGBA
+		visible bool
+	)
+
+	for idx := 0; idx+3 < len(src.Pix); idx += 4 {
+		pixel := color.NRGBA{src.Pix[idx], src.Pix[idx+1], src.Pix[idx+2], src.Pix[idx+3]}
+
+		if pixel.A < symbolicMinAlpha {
+			continue
+		}
+
+		// Colored pixels are not symbolic.
+		if spread(pixel.R, pixel.G, pixel.B) > symbolicTolerance {
+			return false
+		}
+
+		if !visible {
+			first = pixel
+			visible = true
+			continue
+		}
+
+		if spread(first.R, pixel.R) > symbolicTolerance ||
+			spread(first.G, pixel.G) > symbolicTolerance ||
+			spread(first.B, pixel.B) > symbolicTolerance {
+			return false
+		}
+	}
+
+	return visible
+}
+
+// spread returns difference between the largest and the smallest value.
+func spread(values ...uint8) int {
+	return int(slices.Max(values)) - int(slices.Min(values))
+}
+
+// Recolor returns img painted with the color. Alpha of each pixel is the
+// product of its alpha and alpha of the color, so that the shape and
+// antialiasing of the image are preserved.
+func Recolor(img image.Image, c color.Color) *image.NRGBA {
+	src := toNRGBA(img)
+	dst := image.NewNRGBA(src.Rect)
+	fg := color.NRGBAModel.Convert(c).(color.NRGBA)
+
+	for idx := 0; idx+3 < len(src.Pix); idx += 4 {
+		dst.Pix[idx] = fg.R
+		dst.Pix[idx+1] = fg.G
+		dst.Pix[idx+2] = fg.B
+		dst.Pix[idx+3] = uint8((uint16(src.Pix[idx+3])*uint16(fg.A) + 127) / 255)
+	}
+
+	return dst
+}
+
+// RecolorSymbolic returns img painted with the color if it is a symbolic icon,
+// either by its name (see [IsSymbolicName]) or by its pixels (see
+// [IsSymbolic]). Other images are returned as is. Name may be empty for
+// pixmap icons.
+func RecolorSymbolic(img image.Image, name string, c color.Color) image.Image {
+	if IsSymbolicName(name) || IsSymbolic(img) {
+		return Recolor(img, c)
+	}
+
+	return img
+}
+
+// RecolorSymbolicClasses returns symbolic icon rendered from SVG by GTK, such
+// as .symbolic.png files, painted with the colors.
+//
+// GTK encodes style classes in color channels of such icons: red, green, and
+// blue are weights of success, warning, and error colors respectively, and the
+// rest is painted with the foreground color. Black pixels are thus painted with
+// the foreground color, which makes this function suitable for plain symbolic
+// icons as well. Nil colors default to the foreground color.
+func RecolorSymbolicClasses(img image.Image, colors SymbolicColors) *image.NRGBA {
+	fg := toFloatColor(colors.Foreground, nil)
+	classes := [3][4]float64{
+		toFloatColor(colors.Success, colors.Foreground),
+		toFloatColor(colors.Warning, colors.Foreground),
+		toFloatColor(colors.Error, colors.Foreground),
+	}
+
+	src := toNRGBA(img)
+	dst := image.NewNRGBA(src.Rect)
+
+	for idx := 0; idx+3 < len(src.Pix); idx += 4 {
+		weights := [3]float64{
+			float64(src.Pix[idx]) / 255,
+			float64(src.Pix[idx+1]) / 255,
+			float64(src.Pix[idx+2]) / 255,
+		}
+
+		for channel := range 3 {
+			value := fg[channel]
+
+			for class, weight := range weights {
+				value += weight * (classes[class][channel] - fg[channel])
+			}
+
+			dst.Pix[idx+channel] = clampUint8(float32(value * 255))
+		}
+
+		dst.Pix[idx+3] = clampUint8(float32(float64(src.Pix[idx+3]) * fg[3]))
+	}
+
+	return dst
+}
+
+// toFloatColor returns non-premultiplied components of the color in range
+// [0, 1]. If c is nil, def is used. If both are nil, opaque black is returned.
+func toFloatColor(c, def color.Color) [4]float64 {
+	if c == nil {
+		c = def
+	}
+
+	if c == nil {
+		return [4]float64{0, 0, 0, 1}
+	}
+
+	nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+
+	return [4]float64{
+		float64(nrgba.R) / 255,
+		float64(nrgba.G) / 255,
+		float64(nrgba.B) / 255,
+		float64(nrgba.A) / 255,
+	}
+}
+
+// svgStartTag matches the start tag of the root element of SVG document.
+var svgStartTag = regexp.MustCompile(`<svg(?:\s[^>]*)?>`)
+
+// RecolorSymbolicSVG returns symbolic SVG document with a stylesheet that
+// paints it with the colors, in the same way GTK renders symbolic icons. The
+// result can be rendered with any SVG renderer.
+func RecolorSymbolicSVG(svg []byte, colors SymbolicColors) ([]byte, error) {
+	loc := svgStartTag.FindIndex(svg)
+	if loc == nil {
+		return nil, errors.New("recolor svg: missing <svg> element")
+	}
+
+	if bytes.HasSuffix(svg[loc[0]:loc[1]], []byte("/>")) {
+		return nil, errors.New("recolor svg: empty <svg> element")
+	}
+
+	style := "<style type=\"text/css\">" + colors.Stylesheet() + "</style>"
+
+	result := make([]byte, 0, len(svg)+len(style))
+	result = append(result, svg[:loc[1]]...)
+	result = append(result, style...)
+	result = append(result, svg[loc[1]:]...)
+
+	return result, nil
+}
+
+// Stylesheet returns CSS stylesheet that paints symbolic SVG icons with the
+// colors. Nil colors are omitted.
+func (sc SymbolicColors) Stylesheet() string {
+	var sb strings.Builder
+
+	rules := []struct {
+		selector string
+		color    color.Color
+	}{
+		{"rect,circle,path", sc.Foreground},
+		{".success", sc.Success},
+		{".warning", sc.Warning},
+		{".error", sc.Error},
+	}
+
+	for _, rule := range rules {
+		if rule.color == nil {
+			continue
+		}
+
+		fmt.Fprintf(&sb, "%s{fill:%s !important;}", rule.selector, cssColor(rule.color))
+	}
+
+	return sb.String()
+}
+
+// cssColor returns color in CSS rgba() notation.
+func cssColor(c color.Color) string {
+	nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+
+	return fmt.Sprintf("rgba(%d,%d,%d,%.3g)", nrgba.R, nrgba.G, nrgba.B, float64(nrgba.A)/255)
+}
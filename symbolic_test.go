@@ -0,0 +1,183 @@
+package systray
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// testGlyph returns 4x4 image with transparent background, a 2x2 glyph of
+// color c in the middle, and antialiased edge of color edge.
+func testGlyph(c, edge color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+
+	for y := 1; y < 3; y++ {
+		for x := 1; x < 3; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	img.SetNRGBA(0, 1, edge)
+
+	return img
+}
+
+func TestIsSymbolic(t *testing.T) {
+	gray := color.NRGBA{0x33, 0x33, 0x33, 0xff}
+
+	tests := []struct {
+		name string
+		img  image.Image
+		want bool
+	}{
+		{"gray glyph", testGlyph(gray, color.NRGBA{0x33, 0x33, 0x33, 0x80}), true},
+		{"slightly uneven gray", testGlyph(gray, color.NRGBA{0x40, 0x38, 0x33, 0xff}), true},
+		{"faint colored edge", testGlyph(gray, color.NRGBA{0xff, 0, 0, 0x10}), true},
+		{"colored glyph", testGlyph(color.NRGBA{0xe0, 0x1b, 0x24, 0xff}, color.NRGBA{}), false},
+		{"colored edge", testGlyph(gray, color.NRGBA{0, 0, 0xff, 0xff}), false},
+		{"two grays", testGlyph(gray, color.NRGBA{0xee, 0xee, 0xee, 0xff}), false},
+		{"transparent", image.NewNRGBA(image.Rect(0, 0, 4, 4)), false},
+	}
+
+	for _, tt := range tests {
+		if got := IsSymbolic(tt.img); got != tt.want {
+			t.Errorf("%s: IsSymbolic = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecolor(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	src.SetNRGBA(0, 0, color.NRGBA{0x33, 0x33, 0x33, 0xff})
+	src.SetNRGBA(1, 0, color.NRGBA{0x33, 0x33, 0x33, 0x80})
+	src.SetNRGBA(2, 0, color.NRGBA{0x33, 0x33, 0x33, 0})
+
+	tests := []struct {
+		name  string
+		color color.NRGBA
+		want  [3]uint8
+	}{
+		{"opaque", color.NRGBA{0xff, 0xff, 0xff, 0xff}, [3]uint8{0xff, 0x80, 0}},
+		{"translucent", color.NRGBA{0xff, 0xff, 0xff, 0x80}, [3]uint8{0x80, 0x40, 0}},
+	}
+
+	for _, tt := range tests {
+		dst := Recolor(src, tt.color)
+
+		for x, alpha := range tt.want {
+			got := dst.NRGBAAt(x, 0)
+
+			if got.R != tt.color.R || got.G != tt.color.G || got.B != tt.color.B {
+				t.Errorf("%s: pixel %d = %v, want color %v", tt.name, x, got, tt.color)
+			}
+
+			// Alpha is the product of alpha of the pixel and the color.
+			if got.A != alpha {
+				t.Errorf("%s: pixel %d alpha = %#x, want %#x", tt.name, x, got.A, alpha)
+			}
+		}
+	}
+}
+
+func TestRecolorSymbolicClasses(t *testing.T) {
+	fg := color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	colors := SymbolicColors{
+		Foreground: fg,
+		Success:    color.NRGBA{0, 0xff, 0, 0xff},
+		Warning:    color.NRGBA{0xff, 0xff, 0, 0xff},
+		Error:      color.NRGBA{0xff, 0, 0, 0xff},
+	}
+
+	// Color channels of GTK symbolic PNG are weights of style classes.
+	src := image.NewNRGBA(image.Rect(0, 0, 5, 1))
+	src.SetNRGBA(0, 0, color.NRGBA{0, 0, 0, 0xff})
+	src.SetNRGBA(1, 0, color.NRGBA{0xff, 0, 0, 0xff})
+	src.SetNRGBA(2, 0, color.NRGBA{0, 0xff, 0, 0xff})
+	src.SetNRGBA(3, 0, color.NRGBA{0, 0, 0xff, 0x80})
+	src.SetNRGBA(4, 0, color.NRGBA{0x80, 0, 0, 0xff})
+
+	want := []color.NRGBA{
+		fg,
+		{0, 0xff, 0, 0xff},
+		{0xff, 0xff, 0, 0xff},
+		{0xff, 0, 0, 0x80},
+		{0x7f, 0xff, 0x7f, 0xff},
+	}
+
+	dst := RecolorSymbolicClasses(src, colors)
+
+	for x, w := range want {
+		if got := dst.NRGBAAt(x, 0); got != w {
+			t.Errorf("pixel %d = %v, want %v", x, got, w)
+		}
+	}
+
+	// Classes without colors are painted with the foreground color.
+	dst = RecolorSymbolicClasses(src, SymbolicColors{Foreground: fg})
+	if got := dst.NRGBAAt(1, 0); got != fg {
+		t.Errorf("pixel of class without color = %v, want %v", got, fg)
+	}
+}
+
+func TestRecolorSymbolicSVG(t *testing.T) {
+	colors := NewSymbolicColors(color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	style := `<style type="text/css">` + colors.Stylesheet() + `</style>`
+
+	tests := []struct {
+		name    string
+		svg     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "attributes",
+			svg:  `<svg xmlns="http://www.w3.org/2000/svg" width="16"><path d="M0 0h16v16H0z"/></svg>`,
+			want: `<svg xmlns="http://www.w3.org/2000/svg" width="16">` + style + `<path d="M0 0h16v16H0z"/></svg>`,
+		},
+		{
+			name: "declaration and line breaks",
+			svg:  "<?xml version=\"1.0\"?>\n<svg\n  width=\"16\">\n<path/></svg>",
+			want: "<?xml version=\"1.0\"?>\n<svg\n  width=\"16\">" + style + "\n<path/></svg>",
+		},
+		{
+			name: "no attributes",
+			svg:  `<svg><path/></svg>`,
+			want: `<svg>` + style + `<path/></svg>`,
+		},
+		{
+			name:    "self-closing",
+			svg:     `<svg width="16"/>`,
+			wantErr: true,
+		},
+		{
+			name:    "not svg",
+			svg:     `<svgz><path/></svgz>`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := RecolorSymbolicSVG([]byte(tt.svg), colors)
+
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: RecolorSymbolicSVG = %s, want error", tt.name, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		if string(got) != tt.want {
+			t.Errorf("%s: RecolorSymbolicSVG =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+
+	if !strings.Contains(style, ".error{fill:rgba(224,27,36,1) !important;}") {
+		t.Errorf("stylesheet %s does not paint error class", style)
+	}
+}
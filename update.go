@@ -0,0 +1,132 @@
+package systray
+
+import (
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// ItemField identifies a field of [Item] that is populated from a
+// StatusNotifierItem property. Fields can be combined into a bitmask.
+type ItemField uint32
+
+// [Item] fields.
+const (
+	ItemFieldID ItemField = 1 << iota
+	ItemFieldTitle
+	ItemFieldTooltip
+	ItemFieldCategory
+	ItemFieldStatus
+	ItemFieldWindowID
+	ItemFieldIconName
+	ItemFieldIconPixmap
+	ItemFieldOverlayIconName
+	ItemFieldOverlayIconPixmap
+	ItemFieldAttentionIconName
+	ItemFieldAttentionIconPixmap
+	ItemFieldAttentionMovieName
+	ItemFieldIsMenu
+	ItemFieldMenuPath
+	ItemFieldTooltipDescription
+	ItemFieldIconThemePath
+)
+
+var itemFieldNames = []string{
+	"ID",
+	"Title",
+	"Tooltip",
+	"Category",
+	"Status",
+	"WindowID",
+	"IconName",
+	"IconPixmap",
+	"OverlayIconName",
+	"OverlayIconPixmap",
+	"AttentionIconName",
+	"AttentionIconPixmap",
+	"AttentionMovieName",
+	"IsMenu",
+	"MenuPath",
+	"TooltipDescription",
+	"IconThemePath",
+}
+
+// Has reports whether f contains all bits of field.
+func (f ItemField) Has(field ItemField) bool {
+	return f&field == field && field != 0
+}
+
+// String returns names of the fields in f separated by "|".
+func (f ItemField) String() string {
+	names := make([]string, 0, len(itemFieldNames))
+
+	for idx, name := range itemFieldNames {
+		if f&(1<<idx) != 0 {
+			names = append(names, name)
+		}
+	}
+
+	return strings.Join(names, "|")
+}
+
+// ItemChange describes change of a single [Item] field.
+type ItemChange struct {
+	// Field that has changed.
+	Field ItemField
+
+	// Value of the field before the change.
+	Old any
+
+	// Value of the field after the change.
+	New any
+}
+
+// ItemUpdate describes changes applied to [Item] as a result of a single
+// update.
+type ItemUpdate struct {
+	// Bitmask of the changed fields.
+	Fields ItemField
+
+	// Changes of the individual fields, in the order they were applied.
+	Changes []*ItemChange
+
+	// Raw values of the changed properties, including properties that are
+	// not stored in the item.
+	values map[string]dbus.Variant
+}
+
+// Change returns change of the specified field, or nil if field has not
+// changed.
+func (u *ItemUpdate) Change(field ItemField) *ItemChange {
+	for _, change := range u.Changes {
+		if change.Field == field {
+			return change
+		}
+	}
+
+	return nil
+}
+
+// IsEmpty reports whether update contains no changes.
+func (u *ItemUpdate) IsEmpty() bool {
+	return len(u.Changes) == 0
+}
+
+// add records change in the update. Nil changes are ignored.
+func (u *ItemUpdate) add(change *ItemChange) {
+	if change == nil {
+		return
+	}
+
+	u.Fields |= change.Field
+	u.Changes = append(u.Changes, change)
+}
+
+// setValue records raw value of the changed property.
+func (u *ItemUpdate) setValue(name string, value dbus.Variant) {
+	if u.values == nil {
+		u.values = make(map[string]dbus.Variant)
+	}
+
+	u.values[name] = value
+}
@@ -24,20 +24,20 @@ const (
 // [StatusNotifierItem]: https://www.freedesktop.org/wiki/Specifications/StatusNotifierItem/StatusNotifierItem/
 // [StatusNotifierHost]: https://www.freedesktop.org/wiki/Specifications/StatusNotifierItem/StatusNotifierHost/
 type Watcher struct {
-	closed  bool
-	conn    *dbus.Conn
-	mu      sync.Mutex
-	signals chan *dbus.Signal
-	hosts   []string
-	items   []string
+	closed     bool
+	conn       *dbus.Conn
+	mu         sync.Mutex
+	dispatcher *signalDispatcher
+	handler    *signalHandler
+	hosts      []string
+	items      []string
 }
 
 // NewWatcher returns a new instance of [Watcher].
 func NewWatcher(conn *dbus.Conn) *Watcher {
 	return &Watcher{
-		closed:  false,
-		conn:    conn,
-		signals: make(chan *dbus.Signal, 64),
+		closed: false,
+		conn:   conn,
 	}
 }
 
@@ -120,8 +120,11 @@ func (w *Watcher) Close() error {
 		)
 	}
 
-	w.conn.RemoveSignal(w.signals)
-	close(w.signals)
+	if w.dispatcher != nil {
+		w.dispatcher.unregister(w.handler)
+		w.dispatcher.release()
+		w.dispatcher = nil
+	}
 
 	w.closed = true
 
@@ -154,7 +157,7 @@ func (w *Watcher) RegisterStatusNotifierItem(name string, sender dbus.Sender) *d
 	}
 
 	// Check whether item actually implements StatusNotifierItem.
-	if _, err := NewItemWithObjectPath(w.conn, uniqueName, objectPath); err != nil {
+	if err := probeItem(w.conn, uniqueName, objectPath); err != nil {
 		return &dbus.ErrMsgUnknownInterface
 	}
 
@@ -213,34 +216,34 @@ func (w *Watcher) RegisterStatusNotifierHost(name string) *dbus.Error {
 // subscribe monitors org.freedesktop.DBus.NameOwnerChanged signals and
 // unregisters hosts and items when they disappear from D-Bus.
 func (w *Watcher) subscribe() {
-	w.conn.Signal(w.signals)
-
-	go func() {
-		for signal := range w.signals {
-			if signal.Name != "org.freedesktop.DBus.NameOwnerChanged" {
-				continue
-			}
-
-			if len(signal.Body) < 3 {
-				continue
-			}
-
-			name, ok := signal.Body[0].(string)
-			if !ok {
-				continue
-			}
-
-			newOwner, ok := signal.Body[2].(string)
-			if !ok {
-				continue
-			}
-
-			if newOwner == "" {
-				w.tryUnregisterHost(name)
-				w.tryUnregisterItem(name)
-			}
-		}
-	}()
+	w.dispatcher = acquireDispatcher(w.conn)
+	w.handler = w.dispatcher.register("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", w.handleSignal)
+}
+
+// handleSignal handles the org.freedesktop.DBus.NameOwnerChanged signal.
+func (w *Watcher) handleSignal(signal *dbus.Signal) {
+	if signal.Name != "org.freedesktop.DBus.NameOwnerChanged" {
+		return
+	}
+
+	if len(signal.Body) < 3 {
+		return
+	}
+
+	name, ok := signal.Body[0].(string)
+	if !ok {
+		return
+	}
+
+	newOwner, ok := signal.Body[2].(string)
+	if !ok {
+		return
+	}
+
+	if newOwner == "" {
+		w.tryUnregisterHost(name)
+		w.tryUnregisterItem(name)
+	}
 }
 
 // tryUnregisterHost unregisters StatusNotifierHost by name if it was
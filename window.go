@@ -0,0 +1,79 @@
+package systray
+
+import "slices"
+
+// ItemByWindowID returns registered item associated with the window, i.e. item
+// whose [Item.WindowID] equals windowID.
+//
+// Taskbars can use this method to find the tray item of a window. Use
+// [Item.WindowID] for the reverse lookup.
+func (h *Host) ItemByWindowID(windowID uint32) (*Item, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	item, exists := h.windows[windowID]
+	return item, exists
+}
+
+// ItemsByPID returns registered items owned by the process with the given
+// PID.
+//
+// Taskbars can use this method to group tray items with windows of the same
+// process. Use [Item.Process] for the reverse lookup.
+func (h *Host) ItemsByPID(pid uint32) []*Item {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return slices.Clone(h.pids[pid])
+}
+
+// indexWindow adds item to window and process indexes.
+//
+// The caller must hold h.mu.
+func (h *Host) indexWindow(item *Item) {
+	if item.WindowID != 0 {
+		h.windows[item.WindowID] = item
+	}
+
+	if process, err := item.Process(); err == nil {
+		h.pids[process.PID] = append(h.pids[process.PID], item)
+	}
+}
+
+// reindexWindow updates window index after window of the item has changed.
+//
+// The caller must hold h.mu.
+func (h *Host) reindexWindow(item *Item, oldWindowID uint32) {
+	if _, registered := h.items[item.uniqueName]; !registered {
+		return
+	}
+
+	if h.windows[oldWindowID] == item {
+		delete(h.windows, oldWindowID)
+	}
+
+	if item.WindowID != 0 {
+		h.windows[item.WindowID] = item
+	}
+}
+
+// unindexWindow removes item from window and process indexes.
+//
+// The caller must hold h.mu.
+func (h *Host) unindexWindow(item *Item) {
+	if h.windows[item.WindowID] == item {
+		delete(h.windows, item.WindowID)
+	}
+
+	for pid, items := range h.pids {
+		items = slices.DeleteFunc(items, func(i *Item) bool {
+			return i == item
+		})
+
+		if len(items) == 0 {
+			delete(h.pids, pid)
+		} else {
+			h.pids[pid] = items
+		}
+	}
+}
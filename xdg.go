@@ -0,0 +1,50 @@
+package systray
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// xdgDataHome returns base directory for user-specific data files, as defined
+// by the [XDG Base Directory Specification].
+//
+// [XDG Base Directory Specification]: https://specifications.freedesktop.org/basedir-spec/latest/
+func xdgDataHome() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return dir
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".local", "share")
+}
+
+// xdgDataDirs returns base directories for data files in order of preference,
+// as defined by the [XDG Base Directory Specification]. User-specific data
+// directory is the first element.
+//
+// [XDG Base Directory Specification]: https://specifications.freedesktop.org/basedir-spec/latest/
+func xdgDataDirs() []string {
+	dirs := make([]string, 0, 4)
+
+	if home := xdgDataHome(); home != "" {
+		dirs = append(dirs, home)
+	}
+
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+
+	for _, dir := range strings.Split(dataDirs, ":") {
+		if filepath.IsAbs(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs
+}